@@ -0,0 +1,90 @@
+package gee
+
+import (
+	"net/http"
+	"reflect"
+)
+
+/*
+	Handle 把形如 func(*Context, Req) (Resp, error) 的函数转换为 Endpoint：
+	1、Req 必须是结构体，先通过 c.Bind 绑定请求，绑定失败交给分组的 ErrorHandler。
+	2、fn 返回的错误同样交给 c.Error；否则用 c.Negotiate 按 Accept 渲染 Resp。
+	3、Resp 实现了 StatusCoder 时使用它返回的状态码，Resp 为 nil 指针时返回 204。
+	Endpoint 同时记录了 Req/Resp 的类型，通过 RouterGroup.Endpoint 注册后可以生成 OpenAPI 文档。
+*/
+
+// StatusCoder 由响应类型实现，用于指定成功时的状态码
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Endpoint 带有请求、响应类型信息的 handler
+type Endpoint struct {
+	Handler  HandlerFunc
+	Request  reflect.Type
+	Response reflect.Type
+	Summary  string
+	Tags     []string
+}
+
+// Describe 设置 OpenAPI 文档中的摘要和标签
+func (e *Endpoint) Describe(summary string, tags ...string) *Endpoint {
+	e.Summary = summary
+	e.Tags = tags
+	return e
+}
+
+// Handle 创建 Endpoint，例如 gee.Handle(func(c *gee.Context, req GetUserReq) (*User, error) {...})
+func Handle[Req any, Resp any](fn func(*Context, Req) (Resp, error)) *Endpoint {
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	if reqType.Kind() != reflect.Struct {
+		panic("gee: Handle request type must be a struct, got " + reqType.String())
+	}
+	return &Endpoint{
+		Request:  reqType,
+		Response: reflect.TypeOf((*Resp)(nil)).Elem(),
+		Handler: func(c *Context) {
+			var req Req
+			if err := c.Bind(&req); err != nil {
+				c.Error(err)
+				return
+			}
+			resp, err := fn(c, req)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			if c.Written() {
+				return // fn 已经自己写出了响应
+			}
+			// 先判断 nil，nil 指针调用 StatusCode 可能 panic
+			if rv := reflect.ValueOf(resp); !rv.IsValid() || rv.Kind() == reflect.Ptr && rv.IsNil() {
+				c.Status(http.StatusNoContent)
+				return
+			}
+			code := http.StatusOK
+			if sc, ok := interface{}(resp).(StatusCoder); ok {
+				code = sc.StatusCode()
+			}
+			c.Negotiate(code, resp)
+		},
+	}
+}
+
+// endpointRoute 已注册的 Endpoint 及其路由
+type endpointRoute struct {
+	method   string
+	pattern  string
+	endpoint *Endpoint
+}
+
+// Endpoint 注册由 Handle 创建的 Endpoint
+func (group *RouterGroup) Endpoint(method string, pattern string, e *Endpoint) {
+	group.addRoute(method, pattern, e.Handler)
+	engine := group.engine
+	engine.endpoints = append(engine.endpoints, endpointRoute{
+		method:   method,
+		pattern:  group.prefix + pattern,
+		endpoint: e,
+	})
+}
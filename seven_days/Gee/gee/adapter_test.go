@@ -0,0 +1,85 @@
+package gee
+
+import (
+	"net/http"
+	"testing"
+)
+
+type getUserReq struct {
+	ID   int    `param:"id" binding:"required"`
+	Mode string `query:"mode"`
+}
+
+type userResp struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+// StatusCode 使用值接收者，nil *userResp 调用时会 panic
+func (u userResp) StatusCode() int {
+	return http.StatusCreated
+}
+
+func newAdapterEngine() *Engine {
+	r := New()
+	r.Endpoint("GET", "/users/:id", Handle(func(c *Context, req getUserReq) (*userResp, error) {
+		switch req.Mode {
+		case "none":
+			return nil, nil
+		case "error":
+			return nil, ErrForbidden
+		case "map":
+			c.Negotiate(http.StatusOK, H{"id": req.ID})
+			return nil, nil
+		}
+		return &userResp{ID: req.ID, Name: "gee"}, nil
+	}))
+	return r
+}
+
+func TestHandle(t *testing.T) {
+	r := newAdapterEngine()
+	cases := []struct {
+		path, accept string
+		code         int
+		contentType  string
+		body         string
+	}{
+		{"/users/1", "", http.StatusCreated, "application/json", "{\"id\":1,\"name\":\"gee\"}\n"},
+		{"/users/1", "application/xml", http.StatusCreated, "application/xml", "<userResp><id>1</id><name>gee</name></userResp>"},
+		{"/users/1?mode=none", "", http.StatusNoContent, "", ""},
+		{"/users/1?mode=error", "", http.StatusForbidden, "application/json", "{\"error\":\"forbidden\"}\n"},
+		{"/users/x", "", http.StatusBadRequest, "application/json", ""},
+		// encoding/xml 无法编码 map，退回 JSON
+		{"/users/1?mode=map", "application/xml", http.StatusOK, "application/json", "{\"id\":1}\n"},
+	}
+	for _, tc := range cases {
+		w := serve(r, "GET", tc.path, http.Header{"Accept": {tc.accept}})
+		if w.Code != tc.code || w.Header().Get("Content-Type") != tc.contentType || tc.body != "" && w.Body.String() != tc.body {
+			t.Fatalf("GET %s (Accept %q) = %d %q %q", tc.path, tc.accept, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		accept, want string
+	}{
+		{"", "application/json"},
+		{"application/xml", "application/xml"},
+		{"text/xml", "application/xml"},
+		{"text/plain", "text/plain"},
+		{"*/*", "application/json"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "application/json"},
+		{"application/xml;q=0.5, application/json", "application/json"},
+		{"application/json;q=0.5, application/xml;q=0.8", "application/xml"},
+		{"application/xml;q=0, text/plain;q=0.1", "text/plain"},
+		{"application/xml, application/json", "application/xml"},
+		{"image/png", "application/json"},
+	}
+	for _, tc := range cases {
+		if got := negotiateFormat(tc.accept); got != tc.want {
+			t.Fatalf("negotiateFormat(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
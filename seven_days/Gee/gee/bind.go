@@ -0,0 +1,214 @@
+package gee
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+	Bind 把请求数据填充到结构体指针中，字段通过 tag 指明数据来源：
+		param:"id"      路由参数 /user/:id
+		query:"page"    URL 查询参数
+		form:"name"     表单字段
+		header:"X-Token" 请求头
+	没有上述 tag 的字段从请求体中解码，Content-Type 为 JSON 或 XML 时按 json/xml tag 解码。
+	binding:"required" 的字段为零值时返回 *ValidationError。
+*/
+
+var bindSources = []string{"param", "query", "form", "header"}
+
+// Bind 绑定请求数据到 obj，obj 必须是结构体指针
+func (c *Context) Bind(obj interface{}) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gee: Bind requires a non-nil struct pointer, got %T", obj)
+	}
+	if err := c.bindBody(obj); err != nil {
+		return err
+	}
+	if err := c.bindTags(rv.Elem()); err != nil {
+		return err
+	}
+	return validateRequired(rv.Elem())
+}
+
+func (c *Context) bindBody(obj interface{}) error {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return nil
+	}
+	ct, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	var err error
+	switch {
+	case ct == "application/json" || strings.HasSuffix(ct, "+json"):
+		err = json.NewDecoder(c.Req.Body).Decode(obj)
+	case ct == "application/xml" || ct == "text/xml":
+		err = xml.NewDecoder(c.Req.Body).Decode(obj)
+	default:
+		return nil
+	}
+	if err == io.EOF {
+		return nil
+	}
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return err // 例如 ErrBodyTooLarge
+	}
+	if err != nil {
+		return &ValidationError{Message: "invalid request body: " + err.Error()}
+	}
+	return nil
+}
+
+func (c *Context) bindTags(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // 未导出
+			continue
+		}
+		fv := v.Field(i)
+		if field.Anonymous && fv.Kind() == reflect.Struct {
+			if err := c.bindTags(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, source := range bindSources {
+			name, ok := field.Tag.Lookup(source)
+			if !ok || name == "-" {
+				continue
+			}
+			values, ok, err := c.lookup(source, name)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if err := setField(fv, values); err != nil {
+				return &ValidationError{Field: name, Message: err.Error()}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Context) lookup(source, name string) ([]string, bool, error) {
+	switch source {
+	case "param":
+		v, ok := c.Params[name]
+		return []string{v}, ok, nil
+	case "query":
+		v, ok := c.Req.URL.Query()[name]
+		return v, ok, nil
+	case "form":
+		if err := c.parseForm(); err != nil {
+			return nil, false, err
+		}
+		v, ok := c.Req.PostForm[name]
+		return v, ok, nil
+	case "header":
+		v, ok := c.Req.Header[http.CanonicalHeaderKey(name)]
+		return v, ok, nil
+	}
+	return nil, false, nil
+}
+
+func setField(fv reflect.Value, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+func validateRequired(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if field.Anonymous && fv.Kind() == reflect.Struct {
+			if err := validateRequired(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.Tag.Get("binding") == "required" && fv.IsZero() {
+			return &ValidationError{Field: fieldName(field), Message: "is required"}
+		}
+	}
+	return nil
+}
+
+// fieldName 返回字段对外的名称，依次取 json、各绑定来源的 tag 和字段名
+func fieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	for _, source := range bindSources {
+		if name := field.Tag.Get(source); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,243 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Cache 中间件缓存 GET/HEAD 请求的完整响应（状态码、响应头和响应体）：
+	1、缓存的 key 由方法、路径、排序后的查询参数以及 VaryHeaders 指定的请求头组成。
+	2、命中时直接写出缓存的响应并终止后续 handler，响应头中带上 X-Cache: HIT 和 Age。
+	3、未命中时执行 handler，同时把写出的内容记录下来，只有 200 且可以共享的响应才会写入 Store。
+	4、请求带 Cache-Control: no-cache 时跳过缓存读取但仍会刷新缓存，no-store 时完全不使用缓存；
+	   响应带 Cache-Control: private/no-cache/no-store 或 Set-Cookie 时不会被缓存。
+*/
+
+// ResponseStore 保存序列化后的响应，ttl 为建议的过期时间，
+// 实现可以提前淘汰，Cache 中间件读取时也会再次检查是否过期。
+type ResponseStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// CacheConfig Cache 中间件配置
+type CacheConfig struct {
+	Store       ResponseStore // 默认每个中间件使用独立的 MemoryResponseStore，容量为 DefaultResponseStoreBytes
+	VaryHeaders []string      // 参与计算 key 的请求头，例如 Accept-Language
+	MaxBody     int           // 超过该大小的响应不缓存，默认 1MB
+}
+
+var DefaultCacheConfig = CacheConfig{MaxBody: 1 << 20}
+
+// cachedResponse 写入 Store 的响应
+type cachedResponse struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Created time.Time
+	Expires time.Time
+}
+
+// 不随缓存保存的响应头
+var uncachedHeaders = []string{"Set-Cookie", "Connection", "Keep-Alive", "Transfer-Encoding", "Date", "X-Cache", "Age"}
+
+func Cache(ttl time.Duration, config ...CacheConfig) HandlerFunc {
+	cfg := DefaultCacheConfig
+	if len(config) > 0 {
+		cfg = config[0]
+		if cfg.MaxBody <= 0 {
+			cfg.MaxBody = DefaultCacheConfig.MaxBody
+		}
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryResponseStore(0)
+	}
+	return func(c *Context) {
+		if c.Method != http.MethodGet && c.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		directives := cacheControl(c.Req.Header.Get("Cache-Control"))
+		if directives["no-store"] {
+			c.Next()
+			return
+		}
+		key := cacheKey(c.Req, cfg.VaryHeaders)
+		if !directives["no-cache"] {
+			if entry, ok := loadResponse(cfg.Store, key); ok {
+				writeCached(c, entry)
+				return
+			}
+		}
+
+		w := &cacheWriter{ResponseWriter: c.Writer, max: cfg.MaxBody}
+		c.Writer = w
+		defer func() { c.Writer = w.ResponseWriter }() // handler panic 时同样恢复
+		c.SetHeader("X-Cache", "MISS")
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.skip || w.status != http.StatusOK || !cacheable(w.Header()) {
+			return
+		}
+		now := time.Now()
+		entry := cachedResponse{
+			Status:  w.status,
+			Header:  w.Header().Clone(),
+			Body:    w.buf.Bytes(),
+			Created: now,
+			Expires: now.Add(ttl),
+		}
+		for _, h := range uncachedHeaders {
+			entry.Header.Del(h)
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&entry); err != nil {
+			log.Printf("[Cache] encode %s: %v", key, err)
+			return
+		}
+		if err := cfg.Store.Set(key, buf.Bytes(), ttl); err != nil {
+			log.Printf("[Cache] store %s: %v", key, err)
+		}
+	}
+}
+
+// cacheKey 形如 GET /search?page=1&q=gee|Accept-Language=zh
+func cacheKey(req *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.URL.Path)
+	if q := req.URL.Query(); len(q) > 0 {
+		b.WriteByte('?')
+		b.WriteString(q.Encode()) // Encode 按 key 排序
+	}
+	for _, h := range vary {
+		b.WriteByte('|')
+		b.WriteString(http.CanonicalHeaderKey(h))
+		b.WriteByte('=')
+		b.WriteString(strings.Join(req.Header.Values(h), ","))
+	}
+	return b.String()
+}
+
+// cacheControl 解析 Cache-Control 中不带参数的指令
+func cacheControl(header string) map[string]bool {
+	directives := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = true
+		}
+	}
+	return directives
+}
+
+func cacheable(h http.Header) bool {
+	directives := cacheControl(h.Get("Cache-Control"))
+	if directives["private"] || directives["no-cache"] || directives["no-store"] {
+		return false
+	}
+	return h.Get("Set-Cookie") == ""
+}
+
+func loadResponse(store ResponseStore, key string) (*cachedResponse, bool) {
+	data, ok := store.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := new(cachedResponse)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(entry); err != nil {
+		log.Printf("[Cache] decode %s: %v", key, err)
+		return nil, false
+	}
+	if time.Now().After(entry.Expires) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func writeCached(c *Context, entry *cachedResponse) {
+	h := c.Writer.Header()
+	for k, v := range entry.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	h.Set("X-Cache", "HIT")
+	h.Set("Age", strconv.Itoa(int(time.Since(entry.Created).Seconds())))
+	c.Status(entry.Status)
+	c.Writer.Write(entry.Body)
+	c.Abort()
+}
+
+// cacheWriter 在写出响应的同时记录下来，超过 max 或被劫持后放弃记录
+type cacheWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+	max    int
+	skip   bool
+}
+
+// Written 实现 writtenWriter
+func (w *cacheWriter) Written() bool {
+	return w.status != 0 || written(w.ResponseWriter)
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.skip {
+		if w.buf.Len()+len(b) > w.max {
+			w.skip = true
+			w.buf = bytes.Buffer{}
+		} else {
+			w.buf.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *cacheWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gee: ResponseWriter does not implement http.Hijacker")
+	}
+	w.skip = true
+	return h.Hijack()
+}
+
+func (w *cacheWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
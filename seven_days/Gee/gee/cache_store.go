@@ -0,0 +1,103 @@
+package gee
+
+import (
+	"GeeCache/geecache"
+	"GeeCache/geecache/lru"
+	"sync"
+	"time"
+)
+
+/********************************MemoryResponseStore*************************************/
+
+const (
+	DefaultResponseStoreBytes = 64 << 20 // MemoryResponseStore 默认的容量
+	responseSweepInterval     = time.Minute
+)
+
+// responseBytes 实现 lru.Value
+type responseBytes []byte
+
+func (b responseBytes) Len() int {
+	return len(b)
+}
+
+/*
+	MemoryResponseStore 进程内的 ResponseStore，缓存的 key 包含查询参数，客户端可以构造任意多的 key，所以容量必须有上限：
+	1、按 LRU 淘汰，key 和响应的总字节数不超过 maxBytes。
+	2、过期的响应在读取时删除，另外 Set 每隔一分钟回收一次所有过期的响应。
+*/
+type MemoryResponseStore struct {
+	mu        sync.Mutex
+	cache     *lru.Cache
+	nextSweep time.Time
+}
+
+// NewMemoryResponseStore maxBytes <= 0 时使用 DefaultResponseStoreBytes
+func NewMemoryResponseStore(maxBytes int64) *MemoryResponseStore {
+	if maxBytes <= 0 {
+		maxBytes = DefaultResponseStoreBytes
+	}
+	return &MemoryResponseStore{cache: lru.New(maxBytes, nil)}
+}
+
+func (st *MemoryResponseStore) Get(key string) ([]byte, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	value, ok := st.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return value.(responseBytes), true
+}
+
+func (st *MemoryResponseStore) Set(key string, value []byte, ttl time.Duration) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if now := time.Now(); now.After(st.nextSweep) {
+		st.cache.RemoveExpired()
+		st.nextSweep = now.Add(responseSweepInterval)
+	}
+	st.cache.AddWithTTL(key, responseBytes(value), ttl)
+	return nil
+}
+
+var _ ResponseStore = (*MemoryResponseStore)(nil)
+
+/********************************GeeCacheStore*************************************/
+
+/*
+	GeeCacheStore 把响应保存在 geecache.Group 中，内存占用由 Group 的 LRU 限制，过期的响应由 Group 回收。
+	Set 通过 Group.Set 写入 key 所在的节点，注册了 peers 之后，同一个响应可以在所有节点上命中。
+	Group 的 Getter 不会回源，未缓存的 key 返回 geecache.NotFoundError，
+	Group 把它当作 key 不存在而不是加载失败，也不会因此向其他节点回退。
+*/
+type GeeCacheStore struct {
+	group *geecache.Group
+}
+
+// NewGeeCacheStore 创建名为 name 的 geecache.Group 作为 ResponseStore
+func NewGeeCacheStore(name string, cacheBytes int64) *GeeCacheStore {
+	getter := geecache.GetterFunc(func(key string) ([]byte, error) {
+		return nil, &geecache.NotFoundError{Key: key}
+	})
+	return &GeeCacheStore{group: geecache.NewGroup(name, cacheBytes, getter)}
+}
+
+// Group 返回底层的 geecache.Group，可以用来注册 peers
+func (st *GeeCacheStore) Group() *geecache.Group {
+	return st.group
+}
+
+func (st *GeeCacheStore) Get(key string) ([]byte, bool) {
+	view, err := st.group.Get(key)
+	if err != nil {
+		return nil, false
+	}
+	return view.ByteSlice(), true
+}
+
+func (st *GeeCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	return st.group.Set(key, value, ttl)
+}
+
+var _ ResponseStore = (*GeeCacheStore)(nil)
@@ -0,0 +1,117 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newCacheEngine(store ResponseStore) (*Engine, *int) {
+	calls := 0
+	r := New()
+	r.Use(Cache(time.Minute, CacheConfig{Store: store, VaryHeaders: []string{"Accept-Language"}}))
+	handler := func(c *Context) {
+		calls++
+		switch c.Param("kind") {
+		case "missing":
+			c.String(http.StatusNotFound, "missing %d", calls)
+			return
+		case "private":
+			c.CacheControl("private")
+		case "cookie":
+			c.SetCookie(&http.Cookie{Name: "id", Value: "1"})
+		}
+		c.SetHeader("X-Lang", c.Req.Header.Get("Accept-Language"))
+		c.String(http.StatusOK, "%s %d", c.Param("kind"), calls)
+	}
+	r.GET("/:kind", handler)
+	r.POST("/:kind", handler)
+	return r, &calls
+}
+
+func testCache(t *testing.T, store ResponseStore) {
+	r, calls := newCacheEngine(store)
+	zh := http.Header{"Accept-Language": {"zh"}}
+	cases := []struct {
+		method, path string
+		header       http.Header
+		body, xcache string
+	}{
+		{"GET", "/page", nil, "page 1", "MISS"},
+		{"GET", "/page", nil, "page 1", "HIT"},
+		{"GET", "/page?b=2&a=1", nil, "page 2", "MISS"},
+		{"GET", "/page?a=1&b=2", nil, "page 2", "HIT"}, // 查询参数顺序无关
+		{"GET", "/page", zh, "page 3", "MISS"},         // Vary
+		{"GET", "/page", zh, "page 3", "HIT"},
+		{"GET", "/page", http.Header{"Cache-Control": {"no-store"}}, "page 4", ""},
+		{"GET", "/page", http.Header{"Cache-Control": {"no-cache"}}, "page 5", "MISS"}, // 跳过读取，刷新缓存
+		{"GET", "/page", nil, "page 5", "HIT"},
+		{"POST", "/page", nil, "page 6", ""},
+		{"GET", "/missing", nil, "missing 7", "MISS"}, // 非 200 不缓存
+		{"GET", "/missing", nil, "missing 8", "MISS"},
+		{"GET", "/private", nil, "private 9", "MISS"},
+		{"GET", "/private", nil, "private 10", "MISS"},
+		{"GET", "/cookie", nil, "cookie 11", "MISS"},
+		{"GET", "/cookie", nil, "cookie 12", "MISS"},
+	}
+	for i, tc := range cases {
+		w := serve(r, tc.method, tc.path, tc.header)
+		if w.Body.String() != tc.body || w.Header().Get("X-Cache") != tc.xcache {
+			t.Fatalf("case %d: %s %s = %q X-Cache %q, want %q %q", i, tc.method, tc.path,
+				w.Body.String(), w.Header().Get("X-Cache"), tc.body, tc.xcache)
+		}
+	}
+	if *calls != 12 {
+		t.Fatalf("handler called %d times, want 12", *calls)
+	}
+
+	w := serve(r, "GET", "/page", zh)
+	if w.Header().Get("X-Lang") != "zh" || w.Header().Get("Age") == "" || w.Code != http.StatusOK {
+		t.Fatalf("cached headers = %v", w.Header())
+	}
+}
+
+func TestCacheMemoryStore(t *testing.T) {
+	testCache(t, NewMemoryResponseStore(0))
+}
+
+// 不同的查询参数不会让 MemoryResponseStore 无限增长，过期的响应被定期回收
+func TestMemoryResponseStoreBound(t *testing.T) {
+	store := NewMemoryResponseStore(1 << 10)
+	for i := 0; i < 1000; i++ {
+		store.Set(fmt.Sprintf("GET /search?q=%d", i), make([]byte, 16), time.Minute)
+	}
+	if n := store.cache.Bytes(); n > 1<<10 {
+		t.Fatalf("store uses %d bytes, want <= 1KB", n)
+	}
+	if _, ok := store.Get("GET /search?q=0"); ok {
+		t.Fatal("oldest response should be evicted")
+	}
+	if _, ok := store.Get("GET /search?q=999"); !ok {
+		t.Fatal("newest response should be kept")
+	}
+
+	store = NewMemoryResponseStore(0)
+	for i := 0; i < 10; i++ {
+		store.Set(fmt.Sprintf("GET /old?q=%d", i), []byte("x"), time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+	store.nextSweep = time.Time{}
+	store.Set("GET /new", []byte("x"), time.Minute)
+	if n := store.cache.Len(); n != 1 {
+		t.Fatalf("sweep left %d entries, want 1", n)
+	}
+}
+
+func TestCacheGeeCacheStore(t *testing.T) {
+	store := NewGeeCacheStore(fmt.Sprintf("gee-cache-test-%d", time.Now().UnixNano()), 1<<20)
+	testCache(t, store)
+	if _, ok := store.Get("GET /never-cached"); ok {
+		t.Fatal("uncached key should miss")
+	}
+	// 未命中不算作加载失败
+	if s := store.Group().Stats(); s.LocalErrors != 0 {
+		t.Fatalf("cache misses counted as local errors: %+v", s)
+	}
+}
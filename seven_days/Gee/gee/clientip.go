@@ -0,0 +1,152 @@
+package gee
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+/*
+	ClientIP 只有在 RemoteAddr 属于受信任的代理时才会读取代理设置的请求头，
+	否则任何客户端都可以伪造 X-Forwarded-For。
+	1、设置了 TrustedPlatform 时直接使用该请求头，例如 Cloudflare 的 CF-Connecting-IP。
+	2、RemoteAddr 不在 SetTrustedProxies 设置的网段中时，返回 RemoteAddr。
+	3、依次检查 Forwarded、X-Forwarded-For、X-Real-IP：多级代理时从右向左跳过受信任的代理，
+	   第一个不受信任的地址就是客户端地址。
+*/
+
+// 常见平台的客户端地址请求头
+const (
+	PlatformCloudflare      = "CF-Connecting-IP"
+	PlatformGoogleAppEngine = "X-Appengine-Remote-Addr"
+)
+
+// SetTrustedProxies 设置受信任的代理，可以是 IP 或 CIDR，传入 nil 表示不信任任何代理
+func (engine *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("gee: invalid trusted proxy %q", proxy)
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("gee: invalid trusted proxy %q: %v", proxy, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	engine.trustedCIDRs = cidrs
+	return nil
+}
+
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	for _, cidr := range engine.trustedCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteIP 返回 TCP 连接的对端地址，不解析任何请求头
+func (c *Context) RemoteIP() string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return c.Req.RemoteAddr
+	}
+	return host
+}
+
+// fromTrustedProxy 判断请求是否直接来自受信任的代理
+func (c *Context) fromTrustedProxy() bool {
+	ip := net.ParseIP(c.RemoteIP())
+	return ip != nil && c.engine.isTrustedProxy(ip)
+}
+
+// ClientIP 返回客户端的真实地址
+func (c *Context) ClientIP() string {
+	engine := c.engine
+	if engine.TrustedPlatform != "" {
+		if ip := parseIP(c.Req.Header.Get(engine.TrustedPlatform)); ip != nil {
+			return ip.String()
+		}
+	}
+	remote := c.RemoteIP()
+	remoteIP := net.ParseIP(remote)
+	if remoteIP == nil || !engine.isTrustedProxy(remoteIP) {
+		return remote
+	}
+	if ip := engine.firstUntrusted(forwardedFor(c.Req.Header.Values("Forwarded"))); ip != "" {
+		return ip
+	}
+	if ip := engine.firstUntrusted(splitList(c.Req.Header.Values("X-Forwarded-For"))); ip != "" {
+		return ip
+	}
+	if ip := parseIP(c.Req.Header.Get("X-Real-IP")); ip != nil {
+		return ip.String()
+	}
+	return remote
+}
+
+// firstUntrusted 从右向左返回第一个不受信任的地址，全部受信任时返回最左边的地址
+func (engine *Engine) firstUntrusted(hops []string) string {
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseIP(hops[i])
+		if ip == nil {
+			return "" // 格式错误，不再信任这个请求头
+		}
+		if i == 0 || !engine.isTrustedProxy(ip) {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// forwardedFor 解析 RFC 7239 Forwarded 请求头中的 for= 参数
+// 例如 Forwarded: for=192.0.2.60;proto=http, for="[2001:db8::17]:4711"
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				hops = append(hops, strings.Trim(kv[1], `"`))
+			}
+		}
+	}
+	return hops
+}
+
+// parseIP 解析可能带有端口或方括号的地址
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
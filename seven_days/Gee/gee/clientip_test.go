@@ -0,0 +1,66 @@
+package gee
+
+import (
+	"net/http"
+	"testing"
+)
+
+func newClientIPEngine(t *testing.T, platform string) *Engine {
+	r := New()
+	r.TrustedPlatform = platform
+	if err := r.SetTrustedProxies([]string{"10.0.0.0/8", "2001:db8::1"}); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/ip", func(c *Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	return r
+}
+
+func TestSetTrustedProxies(t *testing.T) {
+	for _, proxies := range [][]string{{"10.0.0.300"}, {"10.0.0.0/33"}, {"proxy.local"}} {
+		if err := New().SetTrustedProxies(proxies); err == nil {
+			t.Fatalf("SetTrustedProxies(%v) should fail", proxies)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		name     string
+		platform string
+		remote   string
+		header   http.Header
+		want     string
+	}{
+		{"no proxy", "", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"untrusted peer spoofs XFF", "", "203.0.113.7:1234", http.Header{"X-Forwarded-For": {"1.2.3.4"}}, "203.0.113.7"},
+		{"untrusted peer spoofs X-Real-IP", "", "203.0.113.7:1234", http.Header{"X-Real-Ip": {"1.2.3.4"}}, "203.0.113.7"},
+		{"trusted proxy", "", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.2"}}, "198.51.100.2"},
+		{"trusted chain walked right to left", "", "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.2.3.4, 198.51.100.2", "10.0.0.3, 10.0.0.2"}}, "198.51.100.2"},
+		{"all hops trusted", "", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"malformed XFF hop", "", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"1.2.3.4, evil"}}, "10.0.0.1"},
+		{"Forwarded before XFF", "", "10.0.0.1:1234",
+			http.Header{"Forwarded": {"for=198.51.100.2;proto=https, for=10.0.0.2"}, "X-Forwarded-For": {"1.2.3.4"}}, "198.51.100.2"},
+		{"malformed Forwarded falls back to XFF", "", "10.0.0.1:1234",
+			http.Header{"Forwarded": {"for=unknown"}, "X-Forwarded-For": {"198.51.100.2"}}, "198.51.100.2"},
+		{"malformed Forwarded without fallback", "", "10.0.0.1:1234", http.Header{"Forwarded": {`for="_hidden"`}}, "10.0.0.1"},
+		{"Forwarded IPv6 with port", "", "10.0.0.1:1234", http.Header{"Forwarded": {`for="[2001:db8::17]:4711"`}}, "2001:db8::17"},
+		{"X-Real-IP from trusted proxy", "", "10.0.0.1:1234", http.Header{"X-Real-Ip": {"198.51.100.2"}}, "198.51.100.2"},
+		{"IPv6 peer with port", "", "[2001:db8::2]:443", http.Header{"X-Forwarded-For": {"1.2.3.4"}}, "2001:db8::2"},
+		{"trusted IPv6 proxy", "", "[2001:db8::1]:443", http.Header{"X-Forwarded-For": {"[2001:db8::17]:4711"}}, "2001:db8::17"},
+		{"platform header first", PlatformCloudflare, "203.0.113.7:1234",
+			http.Header{"Cf-Connecting-Ip": {"198.51.100.9"}, "X-Forwarded-For": {"1.2.3.4"}}, "198.51.100.9"},
+		{"platform header over trusted XFF", PlatformCloudflare, "10.0.0.1:1234",
+			http.Header{"Cf-Connecting-Ip": {"198.51.100.9"}, "X-Forwarded-For": {"198.51.100.2"}}, "198.51.100.9"},
+		{"invalid platform header ignored", PlatformCloudflare, "10.0.0.1:1234",
+			http.Header{"Cf-Connecting-Ip": {"garbage"}, "X-Forwarded-For": {"198.51.100.2"}}, "198.51.100.2"},
+	}
+	for _, tc := range cases {
+		r := newClientIPEngine(t, tc.platform)
+		if w := serveFrom(r, tc.remote, "GET", "/ip", tc.header); w.Body.String() != tc.want {
+			t.Fatalf("%s: ClientIP = %q, want %q", tc.name, w.Body.String(), tc.want)
+		}
+	}
+}
@@ -1,9 +1,17 @@
 package gee
 
 import (
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"html/template"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type H map[string]interface{}
@@ -23,11 +31,20 @@ type Context struct {
 	index    int // index是记录当前执行到第几个中间件
 	// engine pointer
 	engine *Engine
+	group  *RouterGroup // 请求所属的分组
+	// 最底层的 ResponseWriter，中间件替换 Writer 之后仍然通过它注册回调
+	writer *responseWriter
+	// 请求作用域的键值对，供中间件之间传递数据
+	keys map[string]interface{}
+	// 请求作用域的模板函数，覆盖解析模板时声明的同名函数
+	funcMap template.FuncMap
 }
 
 func newContext(w http.ResponseWriter, req *http.Request) *Context {
+	writer := &responseWriter{ResponseWriter: w}
 	return &Context{
-		Writer: w,
+		Writer: writer,
+		writer: writer,
 		Req:    req,
 		Path:   req.URL.Path,
 		Method: req.Method,
@@ -43,6 +60,22 @@ func (c *Context) Next() {
 	}
 }
 
+// Abort 阻止后续的中间件和 handler 执行，当前函数仍会执行完
+func (c *Context) Abort() {
+	c.index = len(c.handlers)
+}
+
+// IsAborted 判断是否调用过 Abort
+func (c *Context) IsAborted() bool {
+	return c.index >= len(c.handlers)
+}
+
+// AbortWithStatus 写出状态码并终止后续处理
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Abort()
+}
+
 // /p/:lang/doc
 // 获取 :lang  or *filepath 具体的值
 func (c *Context) Param(key string) string {
@@ -50,7 +83,54 @@ func (c *Context) Param(key string) string {
 	return value
 }
 
+// Set 保存一个请求作用域的值
+func (c *Context) Set(key string, value interface{}) {
+	if c.keys == nil {
+		c.keys = make(map[string]interface{})
+	}
+	c.keys[key] = value
+}
+
+// Get 读取 Set 保存的值
+func (c *Context) Get(key string) (value interface{}, ok bool) {
+	value, ok = c.keys[key]
+	return
+}
+
+// BeforeWrite 注册一个在响应头写出之前执行的回调，例如写入 Set-Cookie
+func (c *Context) BeforeWrite(fn func()) {
+	c.writer.before = append(c.writer.before, fn)
+}
+
+// Written 判断响应是否已经开始写出，中间件替换的 Writer 缓存了响应时同样算作已写出
+func (c *Context) Written() bool {
+	return written(c.Writer) || c.writer.wroteHeader
+}
+
+// Cookie 返回请求中指定名称的 cookie 值
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+// SetCookie 在响应中添加 Set-Cookie 头
+func (c *Context) SetCookie(cookie *http.Cookie) {
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+// PostForm 返回表单字段，请求体超过上限时返回 413 并终止后续处理，
+// 而不是把截断的表单交给 handler
 func (c *Context) PostForm(key string) string {
+	if err := c.parseForm(); err != nil {
+		c.abortBodyTooLarge()
+		return ""
+	}
 	return c.Req.FormValue(key)
 }
 
@@ -74,6 +154,26 @@ func (c *Context) String(code int, format string, values ...interface{}) {
 	c.Writer.Write([]byte(fmt.Sprintf(format, values...)))
 }
 
+// Push 通过 HTTP/2 server push 推送资源，需要在写出响应之前调用。
+// 非 HTTP/2 连接返回 http.ErrNotSupported，调用方可以忽略该错误
+func (c *Context) Push(target string, opts *http.PushOptions) error {
+	if p, ok := c.Writer.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// LastModified 设置 Last-Modified 响应头，需要在 JSON/Data 等方法之前调用，
+// 配合 ETag 中间件可以响应 If-Modified-Since
+func (c *Context) LastModified(t time.Time) {
+	c.SetHeader("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// CacheControl 设置 Cache-Control 响应头，例如 c.CacheControl("public", "max-age=60")
+func (c *Context) CacheControl(directives ...string) {
+	c.SetHeader("Cache-Control", strings.Join(directives, ", "))
+}
+
 func (c *Context) JSON(code int, obj interface{}) {
 	c.SetHeader("Content-Type", "application/json")
 	c.Status(code)
@@ -83,15 +183,133 @@ func (c *Context) JSON(code int, obj interface{}) {
 	}
 }
 
+// XML 先编码到缓冲区，编码失败时返回完整的 500 响应
+func (c *Context) XML(code int, obj interface{}) {
+	data, err := encodeXML(obj)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), 500)
+		return
+	}
+	c.SetHeader("Content-Type", "application/xml")
+	c.Status(code)
+	c.Writer.Write(data)
+}
+
+func encodeXML(obj interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := xml.NewEncoder(&buf).Encode(obj); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+	Negotiate 根据 Accept 请求头选择 JSON、XML 或纯文本格式：
+	1、按 q 值选出客户端最想要的格式，q=0 表示不接受；q 值相同时按出现的顺序。
+	2、最想要的格式都无法提供时使用默认的 JSON，例如浏览器最想要 text/html，
+	   即使它以较低的 q 值接受 application/xml，得到的也是 JSON 而不是 XML。
+	3、encoding/xml 无法编码 H、map 等值，此时同样退回 JSON。
+*/
+func (c *Context) Negotiate(code int, obj interface{}) {
+	switch negotiateFormat(c.Req.Header.Get("Accept")) {
+	case "application/xml":
+		if data, err := encodeXML(obj); err == nil {
+			c.SetHeader("Content-Type", "application/xml")
+			c.Status(code)
+			c.Writer.Write(data)
+			return
+		}
+	case "text/plain":
+		c.String(code, "%v", obj)
+		return
+	}
+	c.JSON(code, obj)
+}
+
+// negotiateFormat 返回 q 值最高的一组媒体类型中第一个支持的格式，没有时返回 application/json
+func negotiateFormat(accept string) string {
+	best := 0.0
+	format := ""
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "q") {
+				if v, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 || q < best {
+			continue
+		}
+		if q > best {
+			best, format = q, ""
+		}
+		if format != "" {
+			continue
+		}
+		switch mediaType {
+		case "application/json", "*/*", "application/*":
+			format = "application/json"
+		case "application/xml", "text/xml":
+			format = "application/xml"
+		case "text/plain":
+			format = "text/plain"
+		}
+	}
+	if format == "" {
+		return "application/json"
+	}
+	return format
+}
+
 func (c *Context) Data(code int, data []byte) {
 	c.Status(code)
 	c.Writer.Write(data)
 }
 
+// SetFunc 设置只对当前请求生效的模板函数，
+// 函数名需要在 LoadHTMLGlob 之前通过 SetFuncMap 声明，内置中间件用到的函数已自动声明
+func (c *Context) SetFunc(name string, fn interface{}) {
+	if c.funcMap == nil {
+		c.funcMap = make(template.FuncMap)
+	}
+	c.funcMap[name] = fn
+}
+
 func (c *Context) HTML(code int, name string, data interface{}) {
+	tmpl := c.group.templates()
+	if tmpl == nil {
+		c.templateError(name, errors.New("no templates loaded, call LoadHTMLGlob first"))
+		return
+	}
+	// 先渲染到缓冲区，出错时可以返回完整的 500 响应而不是半个页面
+	var buf bytes.Buffer
+	if err := tmpl.execute(&buf, name, data, c.funcMap); err != nil {
+		c.templateError(name, err)
+		return
+	}
 	c.SetHeader("Content-Type", "text/html")
 	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
-		c.String(500, err.Error())
+	c.Writer.Write(buf.Bytes())
+}
+
+// templateError 在 debug 模式下返回详细的模板错误，其它模式只记录日志
+func (c *Context) templateError(name string, err error) {
+	log.Printf("[HTML] render %q: %v", name, err)
+	if !IsDebugging() {
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	defined := ""
+	if tmpl := c.group.templates(); tmpl != nil {
+		defined = tmpl.DefinedTemplates()
 	}
+	c.String(http.StatusInternalServerError, "template %q: %v\n%s\n", name, err, defined)
 }
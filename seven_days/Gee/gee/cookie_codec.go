@@ -0,0 +1,139 @@
+package gee
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+/*
+	CookieCodec 负责把任意字节编码为可以放进 cookie 的字符串，并在读取时校验。
+	编码前会在数据前面加上 8 字节的时间戳，Decode 时据此判断是否超过 maxAge。
+	cookie 的名字参与签名（或作为 AEAD 的附加数据），防止把一个 cookie 的值挪到另一个 cookie 上使用。
+*/
+
+// CookieCodec 编码/解码 cookie 的值
+type CookieCodec interface {
+	Encode(name string, value []byte) (string, error)
+	Decode(name string, value string) ([]byte, error)
+}
+
+var (
+	ErrCookieInvalid = errors.New("gee: invalid cookie value")
+	ErrCookieExpired = errors.New("gee: cookie expired")
+)
+
+var cookieEncoding = base64.RawURLEncoding
+
+// stamp 在 value 之前加上当前时间戳
+func stamp(value []byte) []byte {
+	b := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(time.Now().Unix()))
+	copy(b[8:], value)
+	return b
+}
+
+// unstamp 校验时间戳并返回原始数据，maxAge 为 0 表示不过期
+func unstamp(b []byte, maxAge time.Duration) ([]byte, error) {
+	if len(b) < 8 {
+		return nil, ErrCookieInvalid
+	}
+	ts := time.Unix(int64(binary.BigEndian.Uint64(b)), 0)
+	if maxAge > 0 && time.Since(ts) > maxAge {
+		return nil, ErrCookieExpired
+	}
+	return b[8:], nil
+}
+
+// signedCodec 使用 HMAC-SHA256 签名，值本身是明文（base64）
+type signedCodec struct {
+	hashKey []byte
+	maxAge  time.Duration
+}
+
+// NewSignedCodec 创建 HMAC 签名的 CookieCodec，maxAge 为 0 表示不过期
+func NewSignedCodec(hashKey []byte, maxAge time.Duration) CookieCodec {
+	if len(hashKey) == 0 {
+		panic("gee: empty cookie hash key")
+	}
+	return &signedCodec{hashKey: hashKey, maxAge: maxAge}
+}
+
+func (s *signedCodec) mac(name string, payload []byte) []byte {
+	h := hmac.New(sha256.New, s.hashKey)
+	h.Write([]byte(name))
+	h.Write([]byte{'|'})
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+func (s *signedCodec) Encode(name string, value []byte) (string, error) {
+	payload := stamp(value)
+	return cookieEncoding.EncodeToString(payload) + "." +
+		cookieEncoding.EncodeToString(s.mac(name, payload)), nil
+}
+
+func (s *signedCodec) Decode(name string, value string) ([]byte, error) {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrCookieInvalid
+	}
+	payload, err := cookieEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrCookieInvalid
+	}
+	sum, err := cookieEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sum, s.mac(name, payload)) {
+		return nil, ErrCookieInvalid
+	}
+	return unstamp(payload, s.maxAge)
+}
+
+// encryptedCodec 使用 AES-GCM 加密，同时保证机密性和完整性
+type encryptedCodec struct {
+	aead   cipher.AEAD
+	maxAge time.Duration
+}
+
+// NewEncryptedCodec 创建 AES-GCM 加密的 CookieCodec，blockKey 长度必须是 16、24 或 32 字节
+func NewEncryptedCodec(blockKey []byte, maxAge time.Duration) (CookieCodec, error) {
+	block, err := aes.NewCipher(blockKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedCodec{aead: aead, maxAge: maxAge}, nil
+}
+
+func (e *encryptedCodec) Encode(name string, value []byte) (string, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := e.aead.Seal(nonce, nonce, stamp(value), []byte(name))
+	return cookieEncoding.EncodeToString(sealed), nil
+}
+
+func (e *encryptedCodec) Decode(name string, value string) ([]byte, error) {
+	b, err := cookieEncoding.DecodeString(value)
+	if err != nil || len(b) < e.aead.NonceSize() {
+		return nil, ErrCookieInvalid
+	}
+	nonce, sealed := b[:e.aead.NonceSize()], b[e.aead.NonceSize():]
+	payload, err := e.aead.Open(nil, nonce, sealed, []byte(name))
+	if err != nil {
+		return nil, ErrCookieInvalid
+	}
+	return unstamp(payload, e.maxAge)
+}
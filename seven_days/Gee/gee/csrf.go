@@ -0,0 +1,169 @@
+package gee
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+	CSRF 中间件依赖 Sessions 中间件：每个会话生成一个随机 token 保存在会话里。
+	1、每次请求都会把 token 与一次性的随机掩码异或后再输出，页面上的 token 每次都不同，可以抵御 BREACH 攻击。
+	2、模板中通过 {{ csrfToken }} 取得 token，或通过 {{ csrfField }} 输出一个隐藏的表单字段。
+	3、GET/HEAD/OPTIONS/TRACE 之外的请求必须在表单字段或请求头中带上 token，否则返回 403。
+	4、这些请求带有 Origin（没有时看 Referer）请求头时，来源必须与 Host 相同或在 TrustedOrigins 中，否则返回 403。
+*/
+
+const (
+	csrfSessionKey = "_csrf"
+	csrfContextKey = "gee/csrf"
+	csrfTokenLen   = 32
+)
+
+// CSRFConfig CSRF 中间件的配置
+type CSRFConfig struct {
+	FieldName  string      // 表单字段名，默认 _csrf
+	HeaderName string      // 请求头名，默认 X-CSRF-Token
+	OnError    HandlerFunc // 校验失败时调用，默认返回 403
+	// TrustedOrigins 允许跨域提交的来源，例如 https://admin.example.com
+	TrustedOrigins []string
+}
+
+var DefaultCSRFConfig = CSRFConfig{
+	FieldName:  "_csrf",
+	HeaderName: "X-CSRF-Token",
+}
+
+func init() {
+	placeholderFuncs["csrfToken"] = func() string { return "" }
+	placeholderFuncs["csrfField"] = func() template.HTML { return "" }
+}
+
+// CSRF 返回 CSRF 防护中间件，必须在 Sessions 中间件之后使用
+func CSRF(config ...CSRFConfig) HandlerFunc {
+	cfg := DefaultCSRFConfig
+	if len(config) > 0 {
+		cfg = config[0]
+		if cfg.FieldName == "" {
+			cfg.FieldName = DefaultCSRFConfig.FieldName
+		}
+		if cfg.HeaderName == "" {
+			cfg.HeaderName = DefaultCSRFConfig.HeaderName
+		}
+	}
+	return func(c *Context) {
+		s := c.Session()
+		if s == nil {
+			log.Println("[CSRF] no session, use Sessions middleware before CSRF")
+			c.String(http.StatusInternalServerError, "Internal Server Error")
+			c.Abort()
+			return
+		}
+		realToken, ok := s.Get(csrfSessionKey).([]byte)
+		if !ok || len(realToken) != csrfTokenLen {
+			realToken = make([]byte, csrfTokenLen)
+			if _, err := io.ReadFull(rand.Reader, realToken); err != nil {
+				panic(err)
+			}
+			s.Set(csrfSessionKey, realToken)
+		}
+
+		token := maskToken(realToken)
+		c.Set(csrfContextKey, token)
+		c.SetFunc("csrfToken", func() string { return token })
+		c.SetFunc("csrfField", func() template.HTML {
+			return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+				template.HTMLEscapeString(cfg.FieldName), token))
+		})
+
+		if !isSafeMethod(c.Method) {
+			sent := c.Req.Header.Get(cfg.HeaderName)
+			if sent == "" {
+				sent = c.PostForm(cfg.FieldName)
+			}
+			if !validOrigin(c.Req, cfg.TrustedOrigins) || !validToken(realToken, sent) {
+				if cfg.OnError != nil {
+					cfg.OnError(c)
+				} else {
+					c.String(http.StatusForbidden, "403 Forbidden: CSRF check failed\n")
+				}
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// CSRFToken 返回当前请求的 CSRF token，未安装中间件时返回空字符串
+func (c *Context) CSRFToken() string {
+	if token, ok := c.Get(csrfContextKey); ok {
+		return token.(string)
+	}
+	return ""
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// validOrigin 检查请求的来源，Origin 和 Referer 都没有时交给 token 校验
+func validOrigin(req *http.Request, trusted []string) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		origin = req.Header.Get("Referer")
+		if origin == "" {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false // 包括隐私模式下的 Origin: null
+	}
+	if strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+	for _, t := range trusted {
+		if strings.EqualFold(strings.TrimSuffix(t, "/"), u.Scheme+"://"+u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskToken 返回 base64(pad | pad^token)
+func maskToken(token []byte) string {
+	b := make([]byte, 2*len(token))
+	pad, masked := b[:len(token)], b[len(token):]
+	if _, err := io.ReadFull(rand.Reader, pad); err != nil {
+		panic(err)
+	}
+	for i := range token {
+		masked[i] = pad[i] ^ token[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func validToken(realToken []byte, sent string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil || len(b) != 2*len(realToken) {
+		return false
+	}
+	pad, masked := b[:len(realToken)], b[len(realToken):]
+	token := make([]byte, len(realToken))
+	for i := range token {
+		token[i] = pad[i] ^ masked[i]
+	}
+	return subtle.ConstantTimeCompare(token, realToken) == 1
+}
@@ -0,0 +1,117 @@
+package gee
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadTestTemplates(t *testing.T, r *Engine, files map[string]string) {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.LoadHTMLGlob(filepath.Join(dir, "*"))
+}
+
+func newCSRFEngine(t *testing.T, cfg CSRFConfig) *Engine {
+	r := New()
+	loadTestTemplates(t, r, map[string]string{
+		"plain.tmpl": `{{ define "plain" }}hello {{ . }}{{ end }}`,
+		"form.tmpl":  `{{ define "form" }}<form>{{ csrfField }}</form>{{ end }}`,
+	})
+	r.GET("/plain", func(c *Context) {
+		c.HTML(http.StatusOK, "plain", "world")
+	})
+	g := r.Group("/app")
+	g.Use(Sessions("sess", NewCookieStore(NewSignedCodec([]byte("hash-key"), 0))), CSRF(cfg))
+	g.GET("/form", func(c *Context) {
+		c.HTML(http.StatusOK, "form", nil)
+	})
+	g.GET("/token", func(c *Context) {
+		c.String(http.StatusOK, c.CSRFToken())
+	})
+	g.POST("/submit", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+// 普通页面渲染之后，带有请求级函数的页面仍然可以渲染
+func TestHTMLAfterPlainPage(t *testing.T) {
+	r := newCSRFEngine(t, CSRFConfig{})
+	for i := 0; i < 3; i++ {
+		if w := serve(r, "GET", "/plain", nil); w.Code != http.StatusOK || w.Body.String() != "hello world" {
+			t.Fatalf("GET /plain = %d %q", w.Code, w.Body.String())
+		}
+		w := serve(r, "GET", "/app/form", nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<input type="hidden" name="_csrf" value="`) {
+			t.Fatalf("GET /app/form = %d %q", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	r := newCSRFEngine(t, CSRFConfig{TrustedOrigins: []string{"https://admin.example.com"}})
+	w := serve(r, "GET", "/app/token", nil)
+	cookie := sessionCookie(t, w)
+	token := w.Body.String()
+
+	// 每次输出的 token 不同，但都有效
+	again := serve(r, "GET", "/app/token", withCookie(nil, cookie)).Body.String()
+	if again == token {
+		t.Fatal("masked tokens should differ between requests")
+	}
+
+	cases := []struct {
+		name   string
+		cookie *http.Cookie
+		header http.Header
+		code   int
+	}{
+		{"valid token", cookie, http.Header{"X-Csrf-Token": {token}}, http.StatusOK},
+		{"second token", cookie, http.Header{"X-Csrf-Token": {again}}, http.StatusOK},
+		{"missing token", cookie, nil, http.StatusForbidden},
+		{"wrong token", cookie, http.Header{"X-Csrf-Token": {token[:len(token)-2] + "AA"}}, http.StatusForbidden},
+		{"token without session", nil, http.Header{"X-Csrf-Token": {token}}, http.StatusForbidden},
+		{"same origin", cookie, http.Header{"X-Csrf-Token": {token}, "Origin": {"http://example.com"}}, http.StatusOK},
+		{"trusted origin", cookie, http.Header{"X-Csrf-Token": {token}, "Origin": {"https://admin.example.com"}}, http.StatusOK},
+		{"cross origin", cookie, http.Header{"X-Csrf-Token": {token}, "Origin": {"https://evil.com"}}, http.StatusForbidden},
+		{"null origin", cookie, http.Header{"X-Csrf-Token": {token}, "Origin": {"null"}}, http.StatusForbidden},
+		{"cross referer", cookie, http.Header{"X-Csrf-Token": {token}, "Referer": {"https://evil.com/page"}}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		if w := serve(r, "POST", "/app/submit", withCookie(tc.header, tc.cookie)); w.Code != tc.code {
+			t.Fatalf("%s: POST /app/submit = %d, want %d", tc.name, w.Code, tc.code)
+		}
+	}
+}
+
+func TestSecureHeadersHSTS(t *testing.T) {
+	r := New()
+	r.Use(SecureHeaders())
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	if err := r.SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	hsts := func(remote string) string {
+		w := serveFrom(r, remote, "GET", "/", http.Header{"X-Forwarded-Proto": {"https"}})
+		if w.Header().Get("X-Frame-Options") != "DENY" {
+			t.Fatalf("missing security headers: %v", w.Header())
+		}
+		return w.Header().Get("Strict-Transport-Security")
+	}
+	if got := hsts("203.0.113.7:1234"); got != "" {
+		t.Fatalf("X-Forwarded-Proto from an untrusted client set HSTS %q", got)
+	}
+	if got := hsts("10.0.0.1:1234"); !strings.HasPrefix(got, "max-age=31536000") {
+		t.Fatalf("HSTS behind trusted proxy = %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package gee
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+/*
+	handler 可以返回 error，由所在分组的 ErrorHandler 统一转换为响应：
+	1、HandlerFuncE 是返回 error 的 handler，通过 WrapE 转换为 HandlerFunc 后注册。
+	2、c.Error(err) 从请求所属的分组开始，沿着 RouterGroup.parent 依次调用 ErrorHandler，
+	   ErrorHandler 返回 false 表示不处理该错误，交给父分组，最后由 DefaultErrorHandler 兜底。
+	3、Recover 中间件把 panic 转换为 *PanicError 交给同样的流程，因此 panic 也会按分组的格式返回。
+*/
+
+// HandlerFuncE 返回 error 的 handler
+type HandlerFuncE func(*Context) error
+
+// ErrorHandler 把错误转换为响应，返回 false 表示交给父分组处理
+type ErrorHandler func(c *Context, err error) bool
+
+// 常见错误，可以用 fmt.Errorf("...: %w", ErrNotFound) 包装后返回
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// HTTPError 携带状态码的错误
+type HTTPError struct {
+	Code    int
+	Message string
+	Err     error // 原始错误，不会返回给客户端
+}
+
+func NewHTTPError(code int, message string) *HTTPError {
+	return &HTTPError{Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError 请求参数校验失败
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// PanicError 由 Recover 中间件捕获的 panic
+type PanicError struct {
+	Value interface{}
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// ErrorStatus 返回错误对应的状态码和可以返回给客户端的消息
+func ErrorStatus(err error) (int, string) {
+	var he *HTTPError
+	var ve *ValidationError
+	switch {
+	case errors.As(err, &he):
+		return he.Code, he.Message
+	case errors.As(err, &ve):
+		return http.StatusBadRequest, ve.Error()
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized, err.Error()
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden, err.Error()
+	}
+	return http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+}
+
+// errorBody 错误响应体，同时支持 JSON 和 XML
+type errorBody struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Error   string   `json:"error" xml:",chardata"`
+}
+
+func (b errorBody) String() string {
+	return b.Error
+}
+
+// DefaultErrorHandler 按 Accept 返回错误（默认 JSON），5xx 错误只返回通用消息并记录日志
+func DefaultErrorHandler(c *Context, err error) bool {
+	code, message := ErrorStatus(err)
+	if code >= http.StatusInternalServerError {
+		log.Printf("[Error] %s %s: %v", c.Method, c.Path, err)
+	}
+	c.Negotiate(code, errorBody{Error: message})
+	return true
+}
+
+// WrapE 把 HandlerFuncE 转换为 HandlerFunc
+func WrapE(h HandlerFuncE) HandlerFunc {
+	return func(c *Context) {
+		if err := h(c); err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// SetErrorHandler 设置分组的错误处理函数，子分组未处理的错误会交给这里
+func (group *RouterGroup) SetErrorHandler(h ErrorHandler) {
+	group.errorHandler = h
+}
+
+// Error 按分组层级处理错误并终止后续 handler，响应已经写出时只记录日志
+func (c *Context) Error(err error) {
+	c.Abort()
+	if c.Written() {
+		log.Printf("[Error] %s %s: %v (response already written)", c.Method, c.Path, err)
+		return
+	}
+	for group := c.group; group != nil; group = group.parent {
+		if group.errorHandler != nil && group.errorHandler(c, err) {
+			return
+		}
+	}
+	DefaultErrorHandler(c, err)
+}
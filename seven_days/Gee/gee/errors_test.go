@@ -0,0 +1,87 @@
+package gee
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct {
+		err     error
+		code    int
+		message string
+	}{
+		{NewHTTPError(http.StatusTeapot, "teapot"), http.StatusTeapot, "teapot"},
+		{fmt.Errorf("save: %w", NewHTTPError(http.StatusConflict, "conflict")), http.StatusConflict, "conflict"},
+		{&HTTPError{Code: http.StatusBadGateway, Message: "upstream", Err: errors.New("secret")}, http.StatusBadGateway, "upstream"},
+		{&ValidationError{Field: "name", Message: "required"}, http.StatusBadRequest, "name: required"},
+		{fmt.Errorf("bind: %w", &ValidationError{Message: "bad json"}), http.StatusBadRequest, "bad json"},
+		{fmt.Errorf("user 1: %w", ErrNotFound), http.StatusNotFound, "user 1: not found"},
+		{ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+		{fmt.Errorf("admin: %w", ErrForbidden), http.StatusForbidden, "admin: forbidden"},
+		{errors.New("db down"), http.StatusInternalServerError, "Internal Server Error"},
+	}
+	for _, tc := range cases {
+		if code, message := ErrorStatus(tc.err); code != tc.code || message != tc.message {
+			t.Fatalf("ErrorStatus(%v) = %d %q, want %d %q", tc.err, code, message, tc.code, tc.message)
+		}
+	}
+}
+
+// 子分组的 ErrorHandler 不处理时交给父分组，最后由 DefaultErrorHandler 兜底
+func TestErrorHandlerFallback(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.SetErrorHandler(func(c *Context, err error) bool {
+		if !errors.Is(err, ErrNotFound) {
+			return false
+		}
+		c.String(http.StatusNotFound, "api: %v", err)
+		return true
+	})
+	v1 := api.Group("/v1")
+	v1.SetErrorHandler(func(c *Context, err error) bool {
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			return false
+		}
+		c.String(http.StatusBadRequest, "v1: %v", err)
+		return true
+	})
+	v1.GET("/invalid", WrapE(func(c *Context) error {
+		return &ValidationError{Field: "id", Message: "required"}
+	}))
+	v1.GET("/missing", WrapE(func(c *Context) error {
+		return fmt.Errorf("user 1: %w", ErrNotFound)
+	}))
+	v1.GET("/boom", WrapE(func(c *Context) error {
+		return errors.New("db down")
+	}))
+	v1.GET("/ok", WrapE(func(c *Context) error {
+		c.String(http.StatusOK, "ok")
+		return nil
+	}))
+	v1.GET("/written", WrapE(func(c *Context) error {
+		c.String(http.StatusOK, "partial")
+		return errors.New("too late")
+	}))
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/v1/invalid", http.StatusBadRequest, "v1: id: required"},
+		{"/api/v1/missing", http.StatusNotFound, "api: user 1: not found"},
+		{"/api/v1/boom", http.StatusInternalServerError, "{\"error\":\"Internal Server Error\"}\n"},
+		{"/api/v1/ok", http.StatusOK, "ok"},
+		{"/api/v1/written", http.StatusOK, "partial"},
+	}
+	for _, tc := range cases {
+		if w := serve(r, "GET", tc.path, nil); w.Code != tc.code || w.Body.String() != tc.body {
+			t.Fatalf("GET %s = %d %q, want %d %q", tc.path, w.Code, w.Body.String(), tc.code, tc.body)
+		}
+	}
+}
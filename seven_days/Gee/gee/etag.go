@@ -0,0 +1,211 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	ETag 中间件把 GET/HEAD 请求的响应体缓存在内存中，handler 执行完之后：
+	1、计算响应体的摘要作为 ETag（handler 自己设置了 ETag 时直接使用）。
+	2、If-None-Match 命中，或者没有 If-None-Match 而 If-Modified-Since 不早于 Last-Modified 时，返回 304。
+	3、否则把缓存的状态码和响应体写出。
+	响应体超过 MaxBuffer、声明的 Content-Length 超过 MaxBuffer，或 handler 调用了 Flush 时，
+	视为流式响应，立即放弃缓存直接写出。
+	handler panic 时丢弃缓存的响应并恢复 c.Writer，由外层的 Recover 写出错误响应。
+*/
+
+// ETagConfig ETag 中间件配置
+type ETagConfig struct {
+	Weak      bool // 生成弱 ETag W/"..."
+	MaxBuffer int  // 最多缓存的字节数，默认 1MB
+}
+
+var DefaultETagConfig = ETagConfig{MaxBuffer: 1 << 20}
+
+func ETag(config ...ETagConfig) HandlerFunc {
+	cfg := DefaultETagConfig
+	if len(config) > 0 {
+		cfg = config[0]
+		if cfg.MaxBuffer <= 0 {
+			cfg.MaxBuffer = DefaultETagConfig.MaxBuffer
+		}
+	}
+	return func(c *Context) {
+		if c.Method != http.MethodGet && c.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		w := &etagWriter{ResponseWriter: c.Writer, max: cfg.MaxBuffer}
+		c.Writer = w
+		finished := false
+		defer func() {
+			c.Writer = w.ResponseWriter
+			if !finished { // 不调用 recover，保留 panic 原本的调用栈
+				w.buf.Reset()
+			}
+		}()
+		c.Next()
+		finished = true
+		c.Writer = w.ResponseWriter
+		if w.bypass {
+			return
+		}
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		// 写入被缓存时 handler（例如代理）看不到真正的状态码，这里补上
+		c.StatusCode = w.status
+		if w.status != http.StatusOK {
+			w.flush()
+			return
+		}
+
+		h := w.Header()
+		etag := h.Get("ETag")
+		if etag == "" {
+			sum := sha1.Sum(w.buf.Bytes())
+			etag = `"` + hex.EncodeToString(sum[:]) + `"`
+			if cfg.Weak {
+				etag = "W/" + etag
+			}
+			h.Set("ETag", etag)
+		}
+		if notModified(c.Req, h, etag) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			c.StatusCode = http.StatusNotModified
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.flush()
+	}
+}
+
+func notModified(req *http.Request, h http.Header, etag string) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, etag)
+	}
+	ims := req.Header.Get("If-Modified-Since")
+	lm := h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// etagMatch 按弱比较判断 If-None-Match 是否包含 etag
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagWriter 缓存响应，直到确定是否可以返回 304
+type etagWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+	max    int
+	bypass bool
+}
+
+// Written 缓存了状态码或响应体时也算作已写出，避免 c.Error 再追加一个响应
+func (w *etagWriter) Written() bool {
+	return w.status != 0 || written(w.ResponseWriter)
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.bypass {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.bypass && (w.buf.Len()+len(b) > w.max || w.declaredTooLarge()) {
+		w.passthrough()
+	}
+	if w.bypass {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *etagWriter) declaredTooLarge() bool {
+	n, err := strconv.Atoi(w.Header().Get("Content-Length"))
+	return err == nil && n > w.max
+}
+
+// passthrough 放弃缓存，把已缓存的内容写出，之后的写入直接透传
+func (w *etagWriter) passthrough() {
+	w.bypass = true
+	w.flush()
+}
+
+func (w *etagWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+func (w *etagWriter) Flush() {
+	if !w.bypass {
+		w.passthrough()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gee: ResponseWriter does not implement http.Hijacker")
+	}
+	w.bypass = true
+	return h.Hijack()
+}
+
+func (w *etagWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,138 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newETagEngine(cfg ETagConfig) *Engine {
+	r := New()
+	r.Use(ETag(cfg))
+	modified := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.GET("/json", func(c *Context) {
+		c.LastModified(modified)
+		c.CacheControl("public", "max-age=60")
+		c.JSON(http.StatusOK, H{"name": "gee"})
+	})
+	r.POST("/json", func(c *Context) {
+		c.JSON(http.StatusOK, H{"name": "gee"})
+	})
+	r.GET("/missing", func(c *Context) {
+		c.String(http.StatusNotFound, "not found")
+	})
+	r.GET("/large", func(c *Context) {
+		c.String(http.StatusOK, strings.Repeat("x", 64))
+	})
+	r.GET("/stream", func(c *Context) {
+		c.String(http.StatusOK, "a")
+		c.Writer.(http.Flusher).Flush()
+		c.Writer.Write([]byte("b"))
+	})
+	return r
+}
+
+func TestETag(t *testing.T) {
+	r := newETagEngine(ETagConfig{MaxBuffer: 32})
+	w := serve(r, "GET", "/json", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != "{\"name\":\"gee\"}\n" || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("GET /json = %d %q, ETag %q", w.Code, w.Body.String(), etag)
+	}
+	if w.Header().Get("Cache-Control") != "public, max-age=60" || w.Header().Get("Last-Modified") != "Sun, 02 Jan 2022 03:04:05 GMT" {
+		t.Fatalf("missing cache headers: %v", w.Header())
+	}
+
+	cases := []struct {
+		name   string
+		header http.Header
+		code   int
+	}{
+		{"if-none-match", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{"weak if-none-match", http.Header{"If-None-Match": {`"other", W/` + etag}}, http.StatusNotModified},
+		{"stale if-none-match", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		{"if-none-match wins", http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {"Sun, 02 Jan 2022 03:04:05 GMT"}}, http.StatusOK},
+		{"not modified since", http.Header{"If-Modified-Since": {"Sun, 02 Jan 2022 03:04:05 GMT"}}, http.StatusNotModified},
+		{"modified since", http.Header{"If-Modified-Since": {"Sat, 01 Jan 2022 00:00:00 GMT"}}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		w := serve(r, "GET", "/json", tc.header)
+		if w.Code != tc.code {
+			t.Fatalf("%s: code = %d, want %d", tc.name, w.Code, tc.code)
+		}
+		if tc.code == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("Content-Type") != "") {
+			t.Fatalf("%s: 304 should have no body or Content-Type: %q %v", tc.name, w.Body.String(), w.Header())
+		}
+	}
+
+	if w := serve(r, "GET", "/json", nil); w.Header().Get("ETag") != etag {
+		t.Fatalf("ETag changed between identical responses: %q != %q", w.Header().Get("ETag"), etag)
+	}
+	weak := serve(newETagEngine(ETagConfig{Weak: true}), "GET", "/json", nil).Header().Get("ETag")
+	if weak != "W/"+etag {
+		t.Fatalf("weak ETag = %q, want W/%s", weak, etag)
+	}
+}
+
+// 非 200 响应、超过 MaxBuffer 的响应和 Flush 过的响应直接写出，不计算 ETag
+func TestETagBypass(t *testing.T) {
+	r := newETagEngine(ETagConfig{MaxBuffer: 32})
+	for _, tc := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/missing", http.StatusNotFound, "not found"},
+		{"/large", http.StatusOK, strings.Repeat("x", 64)},
+		{"/stream", http.StatusOK, "ab"},
+	} {
+		w := serve(r, "GET", tc.path, http.Header{"If-None-Match": {"*"}})
+		if w.Code != tc.code || w.Body.String() != tc.body || w.Header().Get("ETag") != "" {
+			t.Fatalf("GET %s = %d %q, ETag %q", tc.path, w.Code, w.Body.String(), w.Header().Get("ETag"))
+		}
+	}
+	w := serve(r, "POST", "/json", nil)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != "" {
+		t.Fatalf("POST /json = %d, ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+// 代理路由的状态码经过 ETag 中间件之后仍然记录在 c.StatusCode 中
+func TestETagProxy(t *testing.T) {
+	backend := newBackend(t, "a", http.StatusNotFound)
+	r := New()
+	var status int
+	r.Use(func(c *Context) {
+		c.Next()
+		status = c.StatusCode
+	}, ETag())
+	r.Proxy("/svc", backend.URL)
+	if w := serve(r, "GET", "/svc/x", nil); w.Code != http.StatusNotFound || status != http.StatusNotFound {
+		t.Fatalf("proxy through ETag = %d, c.StatusCode = %d", w.Code, status)
+	}
+}
+
+// handler panic 时缓存的响应被丢弃，Recover 写出完整的 500 响应；
+// 缓存了响应之后再调用 c.Error 不会追加第二个响应体
+func TestETagPanic(t *testing.T) {
+	r := Default()
+	r.Use(ETag())
+	r.GET("/panic", func(c *Context) {
+		c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+	r.GET("/error", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+		c.Error(errors.New("too late"))
+	})
+
+	w := serve(r, "GET", "/panic", nil)
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "{\"error\":\"Internal Server Error\"}\n" {
+		t.Fatalf("GET /panic = %d %q", w.Code, w.Body.String())
+	}
+	if w := serve(r, "GET", "/error", nil); w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("GET /error = %d %q", w.Code, w.Body.String())
+	}
+}
@@ -2,10 +2,14 @@ package gee
 
 import (
 	"html/template"
-	"log"
+	"net"
 	"net/http"
 	"path"
-	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 //HandlerFunc defines the request handler used by gee
@@ -13,10 +17,20 @@ type HandlerFunc func(*Context)
 
 // 分组控制
 type RouterGroup struct {
-	prefix      string
-	middlewares []HandlerFunc // support middleware
-	parent      *RouterGroup  // support nesting
-	engine      *Engine       // all groups share a instance
+	prefix       string
+	middlewares  []HandlerFunc // support middleware
+	parent       *RouterGroup  // support nesting
+	engine       *Engine       // all groups share a instance
+	errorHandler ErrorHandler  // 为 nil 时交给父分组
+	/*
+		以下配置为零值时沿 parent 向上查找，见 group_config.go。
+		模板和 funcMap 原来保存在 Engine 上，现在 Engine 的配置就是根分组的配置，
+		子分组可以使用自己的模板集，例如后台页面和公开 API 使用不同的策略。
+	*/
+	htmlTemplates *templateSet     // html 渲染
+	funcMap       template.FuncMap // html 渲染
+	maxBodySize   int64            // 请求体大小上限，< 0 表示不限制
+	timeout       time.Duration    // 请求超时，< 0 表示不限制
 }
 
 /*
@@ -27,18 +41,21 @@ type RouterGroup struct {
 // Engine 实现 ServerHTTP interface
 type Engine struct {
 	*RouterGroup
-	router *router
-	groups []*RouterGroup
-	/*
-		Engine 示例添加了 *template.Template 和 template.FuncMap对象，
-		前者将所有的模板加载进内存，后者是所有的自定义模板渲染函数。
-	*/
-	htmlTemplates *template.Template // html 渲染
-	funcMap       template.FuncMap   // html 渲染
+	router    *router
+	mu        sync.RWMutex // 保护 groups 和各分组的 middlewares
+	groups    []*RouterGroup
+	endpoints []endpointRoute // 通过 Endpoint 注册的路由，用于生成 OpenAPI 文档
+	// UseH2C 为 true 时 Run 同时支持明文 HTTP/2（h2c），供内部 gRPC 风格的客户端使用
+	UseH2C bool
+	// TrustedPlatform 不为空时 ClientIP 直接信任该请求头，例如 PlatformCloudflare
+	TrustedPlatform string
+	trustedCIDRs    []*net.IPNet // 受信任的代理，见 SetTrustedProxies
+	// ReadHeaderTimeout 读取请求头的超时时间，默认 DefaultReadHeaderTimeout
+	ReadHeaderTimeout time.Duration
 }
 
 func New() *Engine {
-	engine := &Engine{router: newRouter()}
+	engine := &Engine{router: newRouter(), ReadHeaderTimeout: DefaultReadHeaderTimeout}
 	engine.RouterGroup = &RouterGroup{engine: engine}
 	engine.groups = []*RouterGroup{engine.RouterGroup}
 	return engine
@@ -46,6 +63,11 @@ func New() *Engine {
 
 func Default() *Engine {
 	engine := New()
+	if Mode() == TestMode {
+		engine.Use(Recover())
+		return engine
+	}
+	debugWarning("Creating an Engine instance with the Logger and Recover middleware already attached.")
 	engine.Use(Recover(), Logger())
 	return engine
 }
@@ -59,22 +81,41 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 		parent: group,
 		engine: engine,
 	}
+	engine.mu.Lock()
 	engine.groups = append(engine.groups, newGroup)
+	engine.mu.Unlock()
 	return newGroup
 }
 
 // Use is define to add middleware to  the group
 func (group *RouterGroup) Use(middleware ...HandlerFunc) {
+	group.engine.mu.Lock()
 	group.middlewares = append(group.middlewares, middleware...)
+	group.engine.mu.Unlock()
 }
 
 // addRouter 实现建议的路由添加（gin 为前缀树的方式）
 func (group *RouterGroup) addRoute(method string, comp string, handler HandlerFunc) {
 	pattern := group.prefix + comp
-	log.Printf("Route %4s - %s", method, pattern)
+	debugPrint("Route %4s - %s", method, pattern)
 	group.engine.router.addRoute(method, pattern, handler)
 }
 
+// AddRoute 注册路由，可以在服务运行期间调用
+func (group *RouterGroup) AddRoute(method string, pattern string, handler HandlerFunc) {
+	group.addRoute(method, pattern, handler)
+}
+
+// RemoveRoute 删除路由，可以在服务运行期间调用，路由不存在时返回 false
+func (group *RouterGroup) RemoveRoute(method string, pattern string) bool {
+	return group.engine.router.removeRoute(method, group.prefix+pattern)
+}
+
+// ReplaceHandler 原子地替换已注册路由的 handler，路由不存在时返回 false
+func (group *RouterGroup) ReplaceHandler(method string, pattern string, handler HandlerFunc) bool {
+	return group.engine.router.replaceHandler(method, group.prefix+pattern, handler)
+}
+
 func (group *RouterGroup) GET(pattern string, handler HandlerFunc) {
 	group.addRoute("GET", pattern, handler)
 }
@@ -108,25 +149,50 @@ func (group *RouterGroup) Static(relativePath string, root string) {
 
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var middlewares []HandlerFunc
+	current := engine.RouterGroup
+	engine.mu.RLock()
 	for _, group := range engine.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
+		if group.match(req.URL.Path) {
 			middlewares = append(middlewares, group.middlewares...)
+			if len(group.prefix) > len(current.prefix) {
+				current = group // 前缀最长的分组即请求所属的分组
+			}
 		}
 	}
+	engine.mu.RUnlock()
 	c := newContext(w, req)
 	c.handlers = middlewares
 	c.engine = engine
+	c.group = current
+	if cancel := c.applyGroupConfig(); cancel != nil {
+		defer cancel()
+	}
 	engine.router.handle(c)
+	c.checkTimeout()
 }
 
 func (engine *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, engine)
+	return engine.Server(addr).ListenAndServe()
 }
 
-func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
-	engine.funcMap = funcMap
+// RunTLS 以 HTTPS 提供服务，客户端支持时 net/http 会自动协商 HTTP/2，此时可以使用 Context.Push
+func (engine *Engine) RunTLS(addr, certFile, keyFile string) (err error) {
+	return engine.Server(addr).ListenAndServeTLS(certFile, keyFile)
 }
 
-func (engine *Engine) LoadHTMLGlob(pattern string) {
-	engine.htmlTemplates = template.Must(template.New("").Funcs(engine.funcMap).ParseGlob(pattern))
+// Server 返回按 Engine 配置创建的 http.Server，需要更多控制时可以修改后自行启动
+func (engine *Engine) Server(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           engine.Handler(),
+		ReadHeaderTimeout: engine.ReadHeaderTimeout,
+	}
+}
+
+// Handler 返回用于 http.Server 的 Handler，设置了 UseH2C 时同时接受明文 HTTP/2 连接
+func (engine *Engine) Handler() http.Handler {
+	if !engine.UseH2C {
+		return engine
+	}
+	return h2c.NewHandler(engine, &http2.Server{})
 }
@@ -0,0 +1,165 @@
+package gee
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	分组配置按层级继承：子分组没有设置的配置沿 RouterGroup.parent 向上查找，
+	Engine 本身是根分组，所以 engine.LoadHTMLGlob 等方法设置的是全局默认值。
+	例如：
+		admin := r.Group("/admin")
+		admin.LoadHTMLGlob("templates/admin/*")   // 后台使用独立的模板集
+		admin.SetMaxBodySize(32 << 20)            // 允许上传较大的文件
+		api := r.Group("/api")
+		api.SetTimeout(2 * time.Second)
+*/
+
+// placeholderFuncs 声明由中间件按请求注入的模板函数（见 Context.SetFunc），
+// 模板解析时函数必须已经存在，这里提供的只是占位实现
+var placeholderFuncs = template.FuncMap{}
+
+// SetFuncMap 设置分组的模板函数，需要在 LoadHTMLGlob 之前调用
+func (group *RouterGroup) SetFuncMap(funcMap template.FuncMap) {
+	group.funcMap = funcMap
+}
+
+// LoadHTMLGlob 加载分组的模板，未设置 funcMap 时使用父分组的 funcMap
+func (group *RouterGroup) LoadHTMLGlob(pattern string) {
+	group.htmlTemplates = newTemplateSet(pattern, group.funcs())
+	debugPrint("Loaded HTML templates for group %q%s", group.prefix, group.htmlTemplates.DefinedTemplates())
+}
+
+/*
+	html/template 执行过之后就不能再 Clone，而 Funcs 又会修改共享的模板，所以模板集分成三份：
+	1、base 加载后从不执行，只用来克隆。
+	2、plain 是 base 的克隆，没有请求级函数的页面直接用它渲染。
+	3、需要请求级函数时从 pool 中取一份 base 的克隆，替换函数后渲染，用完恢复成占位函数再放回，
+	   每份克隆同一时间只被一个请求使用，不需要每次请求都克隆整个模板集。
+*/
+type templateSet struct {
+	base  *template.Template
+	plain *template.Template
+	funcs template.FuncMap // 占位函数和分组函数，放回 pool 前用来恢复
+	pool  sync.Pool
+}
+
+func newTemplateSet(pattern string, funcMap template.FuncMap) *templateSet {
+	funcs := make(template.FuncMap, len(placeholderFuncs)+len(funcMap))
+	for name, fn := range placeholderFuncs {
+		funcs[name] = fn
+	}
+	for name, fn := range funcMap {
+		funcs[name] = fn
+	}
+	set := &templateSet{
+		base:  template.Must(template.New("").Funcs(funcs).ParseGlob(pattern)),
+		funcs: funcs,
+	}
+	set.plain = template.Must(set.base.Clone())
+	return set
+}
+
+func (set *templateSet) DefinedTemplates() string {
+	return set.base.DefinedTemplates()
+}
+
+// execute 渲染模板 name，funcMap 只对这一次渲染生效
+func (set *templateSet) execute(w io.Writer, name string, data interface{}, funcMap template.FuncMap) error {
+	if len(funcMap) == 0 {
+		return set.plain.ExecuteTemplate(w, name, data)
+	}
+	tmpl, _ := set.pool.Get().(*template.Template)
+	if tmpl == nil {
+		clone, err := set.base.Clone()
+		if err != nil {
+			return err
+		}
+		tmpl = clone
+	}
+	defer func() {
+		set.pool.Put(tmpl.Funcs(set.funcs))
+	}()
+	return tmpl.Funcs(funcMap).ExecuteTemplate(w, name, data)
+}
+
+// SetMaxBodySize 设置请求体大小上限（字节），n < 0 表示不限制
+func (group *RouterGroup) SetMaxBodySize(n int64) {
+	group.maxBodySize = n
+}
+
+// SetTimeout 设置请求超时，通过 c.Req.Context() 通知 handler，d < 0 表示不限制
+func (group *RouterGroup) SetTimeout(d time.Duration) {
+	group.timeout = d
+}
+
+func (group *RouterGroup) funcs() template.FuncMap {
+	for g := group; g != nil; g = g.parent {
+		if g.funcMap != nil {
+			return g.funcMap
+		}
+	}
+	return nil
+}
+
+func (group *RouterGroup) templates() *templateSet {
+	for g := group; g != nil; g = g.parent {
+		if g.htmlTemplates != nil {
+			return g.htmlTemplates
+		}
+	}
+	return nil
+}
+
+func (group *RouterGroup) bodyLimit() int64 {
+	for g := group; g != nil; g = g.parent {
+		if g.maxBodySize != 0 {
+			return g.maxBodySize
+		}
+	}
+	return 0
+}
+
+func (group *RouterGroup) requestTimeout() time.Duration {
+	for g := group; g != nil; g = g.parent {
+		if g.timeout != 0 {
+			return g.timeout
+		}
+	}
+	return 0
+}
+
+// match 判断 path 是否属于分组，按路径段匹配，/admin 不包括 /administrator
+func (group *RouterGroup) match(path string) bool {
+	prefix := group.prefix
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// applyGroupConfig 在执行中间件之前应用请求所属分组的限制
+func (c *Context) applyGroupConfig() context.CancelFunc {
+	if n := c.group.bodyLimit(); n > 0 && c.Req.Body != nil {
+		c.Req.Body = limitedBody{http.MaxBytesReader(c.Writer, c.Req.Body, n)}
+	}
+	if d := c.group.requestTimeout(); d > 0 {
+		ctx, cancel := context.WithTimeout(c.Req.Context(), d)
+		c.Req = c.Req.WithContext(ctx)
+		return cancel
+	}
+	return nil
+}
+
+// checkTimeout 请求超时且 handler 没有写出响应时返回 503
+func (c *Context) checkTimeout() {
+	if c.Req.Context().Err() == context.DeadlineExceeded && !c.Written() {
+		c.Error(NewHTTPError(http.StatusServiceUnavailable, "request timeout"))
+	}
+}
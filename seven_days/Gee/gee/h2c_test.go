@@ -0,0 +1,83 @@
+package gee
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+// 设置 UseH2C 之后，明文 HTTP/2 请求经过完整的中间件链
+func TestH2C(t *testing.T) {
+	r := New()
+	r.UseH2C = true
+	r.Use(func(c *Context) {
+		c.SetHeader("X-Middleware", "1")
+		c.Next()
+	})
+	r.GET("/proto", func(c *Context) {
+		c.String(http.StatusOK, c.Req.Proto)
+	})
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := client.Get(srv.URL + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.ProtoMajor != 2 || string(body) != "HTTP/2.0" || resp.Header.Get("X-Middleware") != "1" {
+		t.Fatalf("h2c response = %s %q, headers %v", resp.Proto, body, resp.Header)
+	}
+
+	// 没有设置 UseH2C 时 Handler 就是 Engine 本身
+	r.UseH2C = false
+	if h, ok := r.Handler().(*Engine); !ok || h != r {
+		t.Fatal("Handler without UseH2C should return the engine")
+	}
+}
+
+// pushRecorder 模拟支持 server push 的 HTTP/2 ResponseWriter
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (w *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+// Push 穿过 ETag 等中间件替换的 Writer 到达底层连接，不支持时返回 http.ErrNotSupported
+func TestPush(t *testing.T) {
+	r := New()
+	r.Use(ETag())
+	var pushErr error
+	r.GET("/", func(c *Context) {
+		pushErr = c.Push("/static/app.css", nil)
+		c.String(http.StatusOK, "index")
+	})
+
+	w := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if pushErr != nil || len(w.pushed) != 1 || w.pushed[0] != "/static/app.css" || w.Body.String() != "index" {
+		t.Fatalf("push over HTTP/2 = %v, pushed %v, body %q", pushErr, w.pushed, w.Body.String())
+	}
+
+	serve(r, "GET", "/", nil)
+	if pushErr != http.ErrNotSupported {
+		t.Fatalf("push over HTTP/1.1 = %v, want http.ErrNotSupported", pushErr)
+	}
+}
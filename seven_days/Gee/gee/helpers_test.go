@@ -0,0 +1,43 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// serve 发送一个没有请求体的请求，header 中的请求头原样复制到请求上
+func serve(r *Engine, method, path string, header http.Header) *httptest.ResponseRecorder {
+	return serveFrom(r, "", method, path, header)
+}
+
+// serveFrom 同 serve，remote 不为空时作为请求的 RemoteAddr
+func serveFrom(r *Engine, remote, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if remote != "" {
+		req.RemoteAddr = remote
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return serveRequest(r, req)
+}
+
+// serveRequest 处理构造好的请求，用于带有请求体的请求
+func serveRequest(r *Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// withCookie 返回加上 cookie 之后的请求头，不修改 header，cookie 为 nil 时原样返回
+func withCookie(header http.Header, cookie *http.Cookie) http.Header {
+	if cookie == nil {
+		return header
+	}
+	h := header.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	h.Add("Cookie", (&http.Cookie{Name: cookie.Name, Value: cookie.Value}).String())
+	return h
+}
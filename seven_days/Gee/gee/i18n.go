@@ -0,0 +1,508 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+	国际化：
+	1、消息目录按语言保存，可以从 JSON/TOML 文件或 embed.FS 加载，文件名即语言，例如 locales/zh-CN.json。
+	   消息可以是字符串，也可以是按复数形式区分的表：{"apples": {"one": "%d apple", "other": "%d apples"}}。
+	2、I18n.Middleware 依次从查询参数、cookie、Accept-Language 中选择语言，
+	   之后可以在 handler 中调用 c.T，在模板中调用 {{ T "hello" .Name }}。
+	3、找不到消息时沿着回退链查找：zh-TW -> zh -> SetFallback 设置的语言 -> 默认语言，最后返回 key 本身。
+	T 的第一个参数为整数时作为复数的数量，消息中可以使用 fmt 的格式化动词。
+*/
+
+// Message 一条消息的各种复数形式，只有一种形式时填写 Other
+type Message struct {
+	Zero  string `json:"zero"`
+	One   string `json:"one"`
+	Two   string `json:"two"`
+	Few   string `json:"few"`
+	Many  string `json:"many"`
+	Other string `json:"other"`
+}
+
+func (m *Message) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		m.Other = s
+		return nil
+	}
+	type plain Message
+	return json.Unmarshal(b, (*plain)(m))
+}
+
+func (m Message) form(category string) string {
+	var s string
+	switch category {
+	case "zero":
+		s = m.Zero
+	case "one":
+		s = m.One
+	case "two":
+		s = m.Two
+	case "few":
+		s = m.Few
+	case "many":
+		s = m.Many
+	}
+	if s == "" {
+		s = m.Other
+	}
+	return s
+}
+
+func (m *Message) set(category, s string) bool {
+	switch category {
+	case "zero":
+		m.Zero = s
+	case "one":
+		m.One = s
+	case "two":
+		m.Two = s
+	case "few":
+		m.Few = s
+	case "many":
+		m.Many = s
+	case "other":
+		m.Other = s
+	default:
+		return false
+	}
+	return true
+}
+
+// PluralRule 根据数量返回复数类别：zero、one、two、few、many 或 other
+type PluralRule func(n int) string
+
+var pluralRules = map[string]PluralRule{
+	"en": pluralOneOther,
+	"de": pluralOneOther,
+	"es": pluralOneOther,
+	"it": pluralOneOther,
+	"fr": func(n int) string {
+		if n == 0 || n == 1 {
+			return "one"
+		}
+		return "other"
+	},
+	"zh": pluralOther,
+	"ja": pluralOther,
+	"ko": pluralOther,
+	"ru": func(n int) string {
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		}
+		return "many"
+	},
+}
+
+func pluralOneOther(n int) string {
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+func pluralOther(int) string {
+	return "other"
+}
+
+// RegisterPluralRule 注册某种语言（不含地区）的复数规则，需要在服务启动前调用
+func RegisterPluralRule(lang string, rule PluralRule) {
+	pluralRules[strings.ToLower(lang)] = rule
+}
+
+// I18n 消息目录
+type I18n struct {
+	DefaultLocale string
+	QueryParam    string // 默认 lang
+	CookieName    string // 默认 lang
+
+	mu        sync.RWMutex
+	catalogs  map[string]map[string]Message // locale -> key -> message
+	fallbacks map[string][]string
+}
+
+func NewI18n(defaultLocale string) *I18n {
+	return &I18n{
+		DefaultLocale: normalizeLocale(defaultLocale),
+		QueryParam:    "lang",
+		CookieName:    "lang",
+		catalogs:      make(map[string]map[string]Message),
+		fallbacks:     make(map[string][]string),
+	}
+}
+
+// normalizeLocale 统一语言标签的格式，例如 zh_cn -> zh-CN
+func normalizeLocale(locale string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// AddMessages 添加消息，已存在的 key 会被覆盖
+func (i *I18n) AddMessages(locale string, messages map[string]Message) {
+	locale = normalizeLocale(locale)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	catalog := i.catalogs[locale]
+	if catalog == nil {
+		catalog = make(map[string]Message, len(messages))
+		i.catalogs[locale] = catalog
+	}
+	for key, msg := range messages {
+		catalog[key] = msg
+	}
+}
+
+// SetFallback 设置 locale 找不到消息时依次查找的语言
+func (i *I18n) SetFallback(locale string, fallbacks ...string) {
+	for j := range fallbacks {
+		fallbacks[j] = normalizeLocale(fallbacks[j])
+	}
+	i.mu.Lock()
+	i.fallbacks[normalizeLocale(locale)] = fallbacks
+	i.mu.Unlock()
+}
+
+// LoadFile 加载 .json 或 .toml 消息文件，文件名（不含扩展名）为语言
+func (i *I18n) LoadFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return i.load(filepath.Base(name), data)
+}
+
+// LoadFS 加载 fsys 中匹配 pattern 的消息文件，可以配合 embed.FS 使用：
+//
+//	//go:embed locales/*
+//	var locales embed.FS
+//	i18n.LoadFS(locales, "locales/*")
+func (i *I18n) LoadFS(fsys fs.FS, pattern string) error {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if err := i.load(path.Base(name), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *I18n) load(base string, data []byte) error {
+	ext := path.Ext(base)
+	locale := strings.TrimSuffix(base, ext)
+	var messages map[string]Message
+	var err error
+	switch strings.ToLower(ext) {
+	case ".json":
+		err = json.Unmarshal(data, &messages)
+	case ".toml":
+		messages, err = parseTOMLMessages(data)
+	default:
+		return fmt.Errorf("gee: unsupported message file %s", base)
+	}
+	if err != nil {
+		return fmt.Errorf("gee: load %s: %v", base, err)
+	}
+	i.AddMessages(locale, messages)
+	return nil
+}
+
+/*
+	parseTOMLMessages 只支持消息文件需要的 TOML 子集：
+		hello = "Hello, %s"          # 注释
+		[apples]
+		one = "%d apple"
+		other = "%d apples"
+	[table] 之后的键值对是该消息的复数形式。
+*/
+func parseTOMLMessages(data []byte) (map[string]Message, error) {
+	messages := make(map[string]Message)
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid table header", lineno)
+			}
+			table = unquoteKey(line[1 : len(line)-1])
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expect key = \"value\"", lineno)
+		}
+		key := unquoteKey(kv[0])
+		value, err := unquoteTOML(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		if table == "" {
+			messages[key] = Message{Other: value}
+			continue
+		}
+		msg := messages[table]
+		if !msg.set(key, value) {
+			return nil, fmt.Errorf("line %d: unknown plural category %q", lineno, key)
+		}
+		messages[table] = msg
+	}
+	return messages, scanner.Err()
+}
+
+func unquoteKey(s string) string {
+	s = strings.TrimSpace(s)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return strings.Trim(s, `'`)
+}
+
+// unquoteTOML 解析字符串值，并去掉行尾注释
+func unquoteTOML(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "'") {
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], nil
+	}
+	if !strings.HasPrefix(s, `"`) {
+		return "", fmt.Errorf("value must be a string")
+	}
+	for end := 1; end < len(s); end++ {
+		if s[end] == '\\' {
+			end++
+			continue
+		}
+		if s[end] == '"' {
+			return strconv.Unquote(s[:end+1])
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// Locales 返回已加载的语言
+func (i *I18n) Locales() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	locales := make([]string, 0, len(i.catalogs))
+	for locale := range i.catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// chain 返回查找消息的语言顺序
+func (i *I18n) chain(locale string) []string {
+	chain := []string{locale}
+	if base := strings.Split(locale, "-")[0]; base != locale {
+		chain = append(chain, base)
+	}
+	chain = append(chain, i.fallbacks[locale]...)
+	return append(chain, i.DefaultLocale)
+}
+
+// Translate 翻译 key，args 的第一个参数为整数时用于选择复数形式
+func (i *I18n) Translate(locale, key string, args ...interface{}) string {
+	locale = normalizeLocale(locale)
+	i.mu.RLock()
+	var msg Message
+	var found string
+	for _, l := range i.chain(locale) {
+		if m, ok := i.catalogs[l][key]; ok {
+			msg, found = m, l
+			break
+		}
+	}
+	i.mu.RUnlock()
+	if found == "" {
+		return key
+	}
+	format := msg.Other
+	if len(args) > 0 {
+		if n, ok := toInt(args[0]); ok {
+			rule, ok := pluralRules[strings.Split(found, "-")[0]]
+			if !ok {
+				rule = pluralOneOther
+			}
+			format = msg.form(rule(n))
+		}
+	}
+	if len(args) == 0 || !strings.Contains(format, "%") {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// match 在已加载的语言中寻找与 locale 匹配的语言
+func (i *I18n) match(locale string) (string, bool) {
+	if locale == "" {
+		return "", false
+	}
+	locale = normalizeLocale(locale)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if _, ok := i.catalogs[locale]; ok {
+		return locale, true
+	}
+	base := strings.Split(locale, "-")[0]
+	if _, ok := i.catalogs[base]; ok {
+		return base, true
+	}
+	for l := range i.catalogs {
+		if strings.Split(l, "-")[0] == base {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// acceptLanguages 按 q 值从高到低返回 Accept-Language 中可以接受的语言
+func acceptLanguages(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			if f = strings.TrimSpace(f); strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue // q=0 表示不接受这种语言
+		}
+		langs = append(langs, lang{tag, q})
+	}
+	sort.SliceStable(langs, func(a, b int) bool { return langs[a].q > langs[b].q })
+	tags := make([]string, len(langs))
+	for j, l := range langs {
+		tags[j] = l.tag
+	}
+	return tags
+}
+
+const localeContextKey = "gee/locale"
+
+func init() {
+	placeholderFuncs["T"] = func(key string, args ...interface{}) string { return key }
+	placeholderFuncs["locale"] = func() string { return "" }
+}
+
+// Middleware 为请求选择语言，并向模板提供 T 和 locale 函数
+func (i *I18n) Middleware() HandlerFunc {
+	return func(c *Context) {
+		locale := i.DefaultLocale
+		candidates := []string{c.Query(i.QueryParam)}
+		if cookie, err := c.Cookie(i.CookieName); err == nil {
+			candidates = append(candidates, cookie)
+		}
+		candidates = append(candidates, acceptLanguages(c.Req.Header.Get("Accept-Language"))...)
+		for _, candidate := range candidates {
+			if l, ok := i.match(candidate); ok {
+				locale = l
+				break
+			}
+		}
+		c.Set(localeContextKey, &requestLocale{i18n: i, locale: locale})
+		c.SetFunc("T", func(key string, args ...interface{}) string {
+			return i.Translate(locale, key, args...)
+		})
+		c.SetFunc("locale", func() string { return locale })
+		c.Next()
+	}
+}
+
+type requestLocale struct {
+	i18n   *I18n
+	locale string
+}
+
+// Locale 返回当前请求的语言，未安装 I18n 中间件时返回空字符串
+func (c *Context) Locale() string {
+	if v, ok := c.Get(localeContextKey); ok {
+		return v.(*requestLocale).locale
+	}
+	return ""
+}
+
+// T 按当前请求的语言翻译 key，未安装 I18n 中间件时返回 key
+func (c *Context) T(key string, args ...interface{}) string {
+	if v, ok := c.Get(localeContextKey); ok {
+		rl := v.(*requestLocale)
+		return rl.i18n.Translate(rl.locale, key, args...)
+	}
+	return key
+}
@@ -0,0 +1,128 @@
+package gee
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestI18n(t *testing.T) *I18n {
+	i := NewI18n("en")
+	dir := t.TempDir()
+	files := map[string]string{
+		"en.json": `{"hello": "Hello, %s", "bye": "Bye", "apples": {"one": "%d apple", "other": "%d apples"}}`,
+		"zh.toml": "hello = \"你好，%s\" # 注释\n[apples]\nother = \"%d 个苹果\"\n",
+		"fr.json": `{"hello": "Bonjour, %s"}`,
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := i.LoadFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.AddMessages("zh-TW", map[string]Message{"hello": {Other: "妳好，%s"}})
+	i.AddMessages("de", map[string]Message{"bye": {Other: "Tschüss"}})
+	i.SetFallback("fr", "de")
+	return i
+}
+
+func TestAcceptLanguages(t *testing.T) {
+	cases := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"zh-CN,zh;q=0.9,en;q=0.8", []string{"zh-CN", "zh", "en"}},
+		{"en;q=0.5, fr", []string{"fr", "en"}},
+		{"fr;q=0, de;q=0.1, *", []string{"de"}},
+		{"fr;q=0.0", []string{}},
+	}
+	for _, tc := range cases {
+		if got := acceptLanguages(tc.header); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("acceptLanguages(%q) = %v, want %v", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	i := newTestI18n(t)
+	cases := []struct {
+		locale, key string
+		args        []interface{}
+		want        string
+	}{
+		{"en", "hello", []interface{}{"gee"}, "Hello, gee"},
+		{"zh_cn", "hello", []interface{}{"gee"}, "你好，gee"}, // zh-CN -> zh
+		{"zh-TW", "hello", []interface{}{"gee"}, "妳好，gee"},
+		{"zh-TW", "bye", nil, "Bye"},                  // zh-TW -> zh -> en
+		{"fr", "bye", nil, "Tschüss"},                 // SetFallback
+		{"en", "apples", []interface{}{1}, "1 apple"}, // 复数
+		{"en", "apples", []interface{}{3}, "3 apples"},
+		{"zh", "apples", []interface{}{1}, "1 个苹果"},
+		{"en", "missing", nil, "missing"},
+	}
+	for _, tc := range cases {
+		if got := i.Translate(tc.locale, tc.key, tc.args...); got != tc.want {
+			t.Fatalf("Translate(%q, %q) = %q, want %q", tc.locale, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestI18nMiddleware(t *testing.T) {
+	i := newTestI18n(t)
+	r := New()
+	loadTestTemplates(t, r, map[string]string{
+		"plain.tmpl": `{{ define "plain" }}plain{{ end }}`,
+		"hello.tmpl": `{{ define "hello" }}{{ locale }}: {{ T "hello" . }}{{ end }}`,
+	})
+	r.Use(i.Middleware())
+	r.GET("/plain", func(c *Context) {
+		c.HTML(http.StatusOK, "plain", nil)
+	})
+	r.GET("/hello", func(c *Context) {
+		c.HTML(http.StatusOK, "hello", "gee")
+	})
+	r.GET("/t", func(c *Context) {
+		c.String(http.StatusOK, "%s %s", c.Locale(), c.T("bye"))
+	})
+
+	request := func(path, cookie, accept string) string {
+		header := http.Header{"Accept-Language": {accept}}
+		if cookie != "" {
+			header = withCookie(header, &http.Cookie{Name: "lang", Value: cookie})
+		}
+		w := serve(r, "GET", path, header)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %q", path, w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	// 普通页面渲染之后，模板中的 T 仍然可以使用
+	if got := request("/plain", "", ""); got != "plain" {
+		t.Fatalf("GET /plain = %q", got)
+	}
+	cases := []struct {
+		path, cookie, accept, want string
+	}{
+		{"/hello", "", "", "en: Hello, gee"},
+		{"/hello", "", "zh-CN,zh;q=0.9", "zh: 你好，gee"},
+		{"/hello", "", "zh-TW", "zh-TW: 妳好，gee"},
+		{"/hello", "", "fr;q=0, zh;q=0.5", "zh: 你好，gee"},
+		{"/hello", "", "ja, fr;q=0.8", "fr: Bonjour, gee"},
+		{"/hello", "fr", "zh", "fr: Bonjour, gee"},
+		{"/hello?lang=zh", "fr", "en", "zh: 你好，gee"},
+		{"/hello?lang=ja", "", "ja", "en: Hello, gee"},
+		{"/t", "", "de", "de Tschüss"},
+	}
+	for _, tc := range cases {
+		if got := request(tc.path, tc.cookie, tc.accept); got != tc.want {
+			t.Fatalf("GET %s (cookie %q, Accept-Language %q) = %q, want %q", tc.path, tc.cookie, tc.accept, got, tc.want)
+		}
+	}
+}
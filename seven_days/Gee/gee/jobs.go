@@ -0,0 +1,391 @@
+package gee
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+/*
+	耗时较长的 handler 可以改为异步执行：
+	1、m.Async(h) 返回的 HandlerFunc 先在请求中执行 h（解析参数、校验等），h 返回真正的任务 JobFunc，
+	   任务进入队列后立即返回 202，Location 指向任务状态的 URL。
+	2、group.Jobs(prefix, m) 注册任务相关的路由：
+		GET    prefix/:id         任务状态
+		GET    prefix/:id/result  任务结果，未完成时返回 202
+		DELETE prefix/:id         取消任务
+	3、任务由固定数量的 worker 执行，队列满时返回 503；取消通过 JobFunc 的 ctx 传递。
+	4、设置 Store 后任务状态每次变化都会保存，内存中找不到的任务会从 Store 中读取，例如服务重启之后。
+	   Store.Save 在 m.mu 之外调用，较慢的 Store 不会阻塞 Submit、Get 和 Cancel。
+*/
+
+// JobStatus 任务状态
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Done 判断任务是否已经结束
+func (s JobStatus) Done() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCanceled
+}
+
+// JobFunc 在后台执行的任务，ctx 在任务被取消或 JobManager 关闭时结束
+type JobFunc func(ctx context.Context) (interface{}, error)
+
+// JobHandler 在请求中执行，返回需要在后台执行的任务
+type JobHandler func(c *Context) (JobFunc, error)
+
+// JobInfo 任务状态的快照，也是状态接口返回的内容
+type JobInfo struct {
+	ID         string      `json:"id"`
+	Status     JobStatus   `json:"status"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
+
+// JobStore 任务状态的持久化
+type JobStore interface {
+	Save(info JobInfo) error
+	Load(id string) (*JobInfo, error)
+}
+
+var ErrJobQueueFull = NewHTTPError(http.StatusServiceUnavailable, "job queue is full")
+
+type job struct {
+	info   JobInfo
+	fn     JobFunc
+	ctx    context.Context
+	cancel context.CancelFunc
+	saveMu sync.Mutex // 串行化同一个任务的 Save，保证最后保存的是最新的状态
+}
+
+// JobManager 管理异步任务，创建后可以修改导出字段进行配置
+type JobManager struct {
+	Store     JobStore
+	Retention time.Duration // 结束的任务在内存中保留多久，默认 1h，第一次 Submit 之后修改不再生效
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	queue   chan *job
+	ctx     context.Context
+	cancel  context.CancelFunc
+	prefix  string // 状态 URL 的前缀，由 Jobs 设置
+	closed  bool
+	purging sync.Once
+}
+
+// NewJobManager 启动 workers 个 worker，最多排队 queueSize 个任务
+func NewJobManager(workers, queueSize int) *JobManager {
+	if workers <= 0 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &JobManager{
+		Retention: time.Hour,
+		jobs:      make(map[string]*job),
+		queue:     make(chan *job, queueSize),
+		ctx:       ctx,
+		cancel:    cancel,
+		prefix:    "/jobs",
+	}
+	for i := 0; i < workers; i++ {
+		go m.worker()
+	}
+	return m
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Submit 把任务加入队列
+func (m *JobManager) Submit(fn JobFunc) (JobInfo, error) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	j := &job{
+		info:   JobInfo{ID: newJobID(), Status: JobPending, CreatedAt: time.Now()},
+		fn:     fn,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		cancel()
+		return JobInfo{}, errors.New("gee: job manager closed")
+	}
+	m.purge()
+	m.purging.Do(m.startPurge)
+	select {
+	case m.queue <- j:
+	default:
+		m.mu.Unlock()
+		cancel()
+		return JobInfo{}, ErrJobQueueFull
+	}
+	m.jobs[j.info.ID] = j
+	info := j.info
+	m.mu.Unlock()
+	m.save(j)
+	return info, nil
+}
+
+// Get 返回任务状态，内存中没有时从 Store 中读取
+func (m *JobManager) Get(id string) (JobInfo, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if ok {
+		info := j.info
+		m.mu.Unlock()
+		return info, nil
+	}
+	m.mu.Unlock()
+	if m.Store != nil {
+		info, err := m.Store.Load(id)
+		if err != nil {
+			return JobInfo{}, err
+		}
+		if info != nil {
+			return *info, nil
+		}
+	}
+	return JobInfo{}, fmt.Errorf("job %s: %w", id, ErrNotFound)
+}
+
+// Cancel 取消任务，已经结束的任务不受影响
+func (m *JobManager) Cancel(id string) (JobInfo, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return m.Get(id)
+	}
+	j.cancel()
+	pending := j.info.Status == JobPending
+	if pending {
+		m.finish(j, nil, context.Canceled)
+	}
+	info := j.info
+	m.mu.Unlock()
+	if pending {
+		m.save(j)
+	}
+	return info, nil
+}
+
+// Close 取消所有任务并停止 worker
+func (m *JobManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	m.closed = true
+	m.cancel()
+	close(m.queue)
+}
+
+func (m *JobManager) worker() {
+	for j := range m.queue {
+		m.run(j)
+	}
+}
+
+func (m *JobManager) run(j *job) {
+	m.mu.Lock()
+	if j.info.Status != JobPending {
+		m.mu.Unlock()
+		return
+	}
+	if err := j.ctx.Err(); err != nil {
+		m.finish(j, nil, err)
+		m.mu.Unlock()
+		m.save(j)
+		return
+	}
+	now := time.Now()
+	j.info.Status = JobRunning
+	j.info.StartedAt = &now
+	m.mu.Unlock()
+	m.save(j)
+
+	result, err := j.call()
+
+	m.mu.Lock()
+	m.finish(j, result, err)
+	m.mu.Unlock()
+	m.save(j)
+	j.cancel()
+}
+
+// call 执行任务，panic 按失败处理
+func (j *job) call() (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Jobs] %s panic: %v\n%s", j.info.ID, r, debug.Stack())
+			err = &PanicError{Value: r}
+		}
+	}()
+	return j.fn(j.ctx)
+}
+
+// finish 记录任务结果，调用时需要持有 m.mu，释放之后再调用 save
+func (m *JobManager) finish(j *job, result interface{}, err error) {
+	switch {
+	case err != nil && j.ctx.Err() != nil && errors.Is(err, j.ctx.Err()):
+		j.info.Status = JobCanceled
+		j.info.Error = err.Error()
+	case err != nil:
+		j.info.Status = JobFailed
+		_, j.info.Error = ErrorStatus(err)
+	default:
+		j.info.Status = JobSucceeded
+		j.info.Result = result
+	}
+	now := time.Now()
+	j.info.FinishedAt = &now
+}
+
+// purge 删除超过 Retention 的已结束任务，调用时需要持有 m.mu
+func (m *JobManager) purge() {
+	if m.Retention <= 0 {
+		return
+	}
+	deadline := time.Now().Add(-m.Retention)
+	for id, j := range m.jobs {
+		if j.info.Status.Done() && j.info.FinishedAt.Before(deadline) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// startPurge 在第一次 Submit 时启动定期清理，不再有新任务提交时结束的任务也会被删除，Close 之后停止
+func (m *JobManager) startPurge() {
+	if m.Retention <= 0 {
+		return
+	}
+	interval := m.Retention
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.mu.Lock()
+				m.purge()
+				m.mu.Unlock()
+			case <-m.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// save 保存任务当前的状态，调用时不能持有 m.mu
+func (m *JobManager) save(j *job) {
+	if m.Store == nil {
+		return
+	}
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+	m.mu.Lock()
+	info := j.info
+	m.mu.Unlock()
+	if err := m.Store.Save(info); err != nil {
+		log.Printf("[Jobs] save %s: %v", info.ID, err)
+	}
+}
+
+// StatusURL 返回任务状态的 URL
+func (m *JobManager) StatusURL(id string) string {
+	m.mu.Lock()
+	prefix := m.prefix
+	m.mu.Unlock()
+	return path.Join(prefix, id)
+}
+
+// Async 返回异步执行 h 的 HandlerFunc，h 返回错误时按普通 handler 的错误处理
+func (m *JobManager) Async(h JobHandler) HandlerFunc {
+	return func(c *Context) {
+		fn, err := h(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		info, err := m.Submit(fn)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		url := m.StatusURL(info.ID)
+		c.SetHeader("Location", url)
+		c.JSON(http.StatusAccepted, H{"id": info.ID, "status": info.Status, "status_url": url})
+	}
+}
+
+// Jobs 在 prefix 下注册任务状态、结果和取消的路由
+func (group *RouterGroup) Jobs(prefix string, m *JobManager) {
+	m.mu.Lock()
+	m.prefix = path.Join("/", group.prefix, prefix)
+	m.mu.Unlock()
+	group.GET(path.Join(prefix, ":id"), WrapE(func(c *Context) error {
+		info, err := m.Get(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		c.JSON(http.StatusOK, info)
+		return nil
+	}))
+	group.GET(path.Join(prefix, ":id/result"), WrapE(func(c *Context) error {
+		info, err := m.Get(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		switch info.Status {
+		case JobSucceeded:
+			c.JSON(http.StatusOK, info.Result)
+		case JobPending, JobRunning:
+			c.SetHeader("Location", m.StatusURL(info.ID))
+			c.SetHeader("Retry-After", "1")
+			c.JSON(http.StatusAccepted, info)
+		default:
+			return &HTTPError{Code: http.StatusConflict, Message: "job " + string(info.Status) + ": " + info.Error}
+		}
+		return nil
+	}))
+	group.AddRoute(http.MethodDelete, path.Join(prefix, ":id"), WrapE(func(c *Context) error {
+		info, err := m.Cancel(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if !info.Status.Done() { // 正在执行的任务在 JobFunc 返回后才会变为 canceled
+			c.JSON(http.StatusAccepted, info)
+			return nil
+		}
+		c.JSON(http.StatusOK, info)
+		return nil
+	}))
+}
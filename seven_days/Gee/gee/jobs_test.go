@@ -0,0 +1,234 @@
+package gee
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitJob 等待任务进入 status
+func waitJob(t *testing.T, m *JobManager, id string, status JobStatus) JobInfo {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		info, err := m.Get(id)
+		if err == nil && info.Status == status {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s = %+v, %v; want %s", id, info, err, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newJobsEngine(m *JobManager, fns map[string]JobFunc) *Engine {
+	r := New()
+	r.POST("/work/:name", m.Async(func(c *Context) (JobFunc, error) {
+		fn, ok := fns[c.Param("name")]
+		if !ok {
+			return nil, NewHTTPError(http.StatusBadRequest, "unknown job")
+		}
+		return fn, nil
+	}))
+	r.Jobs("/jobs", m)
+	return r
+}
+
+func submitJob(t *testing.T, r *Engine, name string) string {
+	w := serve(r, "POST", "/work/"+name, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /work/%s = %d %q", name, w.Code, w.Body.String())
+	}
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Location") != "/jobs/"+body.ID {
+		t.Fatalf("Location = %q", w.Header().Get("Location"))
+	}
+	return body.ID
+}
+
+func TestJobs(t *testing.T) {
+	m := NewJobManager(1, 1)
+	defer m.Close()
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	r := newJobsEngine(m, map[string]JobFunc{
+		"ok": func(ctx context.Context) (interface{}, error) {
+			return H{"answer": 42}, nil
+		},
+		"block": func(ctx context.Context) (interface{}, error) {
+			started <- struct{}{}
+			select {
+			case <-release:
+				return "done", nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+		"panic": func(ctx context.Context) (interface{}, error) {
+			panic("boom")
+		},
+	})
+
+	id := submitJob(t, r, "ok")
+	waitJob(t, m, id, JobSucceeded)
+	if w := serve(r, "GET", "/jobs/"+id+"/result", nil); w.Code != http.StatusOK || w.Body.String() != "{\"answer\":42}\n" {
+		t.Fatalf("GET result = %d %q", w.Code, w.Body.String())
+	}
+
+	id = submitJob(t, r, "panic")
+	info := waitJob(t, m, id, JobFailed)
+	if info.Error == "" || info.FinishedAt == nil {
+		t.Fatalf("panicked job = %+v", info)
+	}
+	if w := serve(r, "GET", "/jobs/"+id+"/result", nil); w.Code != http.StatusConflict {
+		t.Fatalf("result of failed job = %d", w.Code)
+	}
+
+	// worker 被 running 占用，pending 在队列中，队列已满
+	running := submitJob(t, r, "block")
+	<-started
+	pending := submitJob(t, r, "block")
+	if w := serve(r, "POST", "/work/block", nil); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("submit to a full queue = %d, want 503", w.Code)
+	}
+	if w := serve(r, "GET", "/jobs/"+pending+"/result", nil); w.Code != http.StatusAccepted || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("result of pending job = %d", w.Code)
+	}
+
+	if w := serve(r, "DELETE", "/jobs/"+pending, nil); w.Code != http.StatusOK {
+		t.Fatalf("cancel pending job = %d %q", w.Code, w.Body.String())
+	}
+	waitJob(t, m, pending, JobCanceled)
+	if w := serve(r, "DELETE", "/jobs/"+running, nil); w.Code != http.StatusAccepted {
+		t.Fatalf("cancel running job = %d %q", w.Code, w.Body.String())
+	}
+	waitJob(t, m, running, JobCanceled)
+
+	// 已经结束的任务不受取消影响
+	if w := serve(r, "DELETE", "/jobs/"+id, nil); w.Code != http.StatusOK {
+		t.Fatalf("cancel finished job = %d", w.Code)
+	}
+	if info, _ := m.Get(id); info.Status != JobFailed {
+		t.Fatalf("finished job changed to %s", info.Status)
+	}
+	if w := serve(r, "GET", "/jobs/unknown", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown job = %d", w.Code)
+	}
+}
+
+func TestJobsPurge(t *testing.T) {
+	m := NewJobManager(1, 4)
+	defer m.Close()
+	m.Retention = 100 * time.Millisecond
+	ok := func(ctx context.Context) (interface{}, error) { return nil, nil }
+	first, _ := m.Submit(ok)
+	waitJob(t, m, first.ID, JobSucceeded)
+	m.mu.Lock()
+	old := time.Now().Add(-time.Hour)
+	m.jobs[first.ID].info.FinishedAt = &old
+	m.mu.Unlock()
+	second, err := m.Submit(ok) // Submit 时清理过期的任务
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("purged job: %v, want ErrNotFound", err)
+	}
+	waitJob(t, m, second.ID, JobSucceeded)
+
+	// 不再提交任务时，结束的任务由定期清理删除
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := m.Get(second.ID); errors.Is(err, ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("finished job was not purged without further submissions")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// slowStore 的 Save 在 release 关闭前阻塞
+type slowStore struct {
+	mu      sync.Mutex
+	saved   map[string]JobInfo
+	release chan struct{}
+}
+
+func (s *slowStore) Save(info JobInfo) error {
+	<-s.release
+	s.mu.Lock()
+	s.saved[info.ID] = info
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *slowStore) Load(id string) (*JobInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info, ok := s.saved[id]; ok {
+		return &info, nil
+	}
+	return nil, nil
+}
+
+// Store.Save 阻塞时 Get 和 Cancel 不受影响，保存的最终状态是最新的
+func TestJobsSlowStore(t *testing.T) {
+	store := &slowStore{saved: make(map[string]JobInfo), release: make(chan struct{})}
+	m := NewJobManager(1, 4)
+	defer m.Close()
+	m.Store = store
+
+	submitted := make(chan JobInfo)
+	go func() {
+		info, _ := m.Submit(func(ctx context.Context) (interface{}, error) { return "ok", nil })
+		submitted <- info
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		m.Get("unknown")
+		m.Cancel("unknown")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Get blocked by a slow Store.Save")
+	}
+
+	close(store.release)
+	info := <-submitted
+	waitJob(t, m, info.ID, JobSucceeded)
+
+	// 内存中的任务被清理之后从 Store 中读取
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		store.mu.Lock()
+		saved := store.saved[info.ID]
+		store.mu.Unlock()
+		if saved.Status == JobSucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("store has %+v, want succeeded", saved)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	m.mu.Lock()
+	delete(m.jobs, info.ID)
+	m.mu.Unlock()
+	if loaded, err := m.Get(info.ID); err != nil || loaded.Status != JobSucceeded {
+		t.Fatalf("Get from store = %+v, %v", loaded, err)
+	}
+}
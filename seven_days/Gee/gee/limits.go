@@ -0,0 +1,137 @@
+package gee
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+/*
+	请求体限制：
+	1、分组（包括作为根分组的 Engine）通过 SetMaxBodySize 设置上限，
+	   Content-Length 超过上限的请求直接返回 413，其余请求（例如 chunked）读取超过上限时读取方得到 ErrBodyTooLarge，
+	   通过 PostForm 读取表单时直接返回 413，Bind 返回 ErrBodyTooLarge。
+	2、Engine.ReadHeaderTimeout 限制读取请求头的时间，防止连接建立后迟迟不发送请求头。
+	3、MinThroughput 中间件要求请求体在宽限期之后保持最低的平均速率，
+	   每次读取前把连接的读超时设置为“保持该速率所允许的最晚时间”，一点一点发送数据的客户端会被断开。
+*/
+
+var (
+	ErrBodyTooLarge = &HTTPError{Code: http.StatusRequestEntityTooLarge, Message: "request body too large"}
+	ErrSlowBody     = &HTTPError{Code: http.StatusRequestTimeout, Message: "request body too slow"}
+)
+
+// DefaultReadHeaderTimeout New 创建的 Engine 读取请求头的超时时间
+const DefaultReadHeaderTimeout = 10 * time.Second
+
+// limitedBody 把 http.MaxBytesReader 的错误转换为 ErrBodyTooLarge
+type limitedBody struct {
+	io.ReadCloser
+}
+
+func (b limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		err = ErrBodyTooLarge
+	}
+	return n, err
+}
+
+// bodyTooLarge 根据 Content-Length 提前判断请求体是否超过上限
+func (c *Context) bodyTooLarge() bool {
+	n := c.group.bodyLimit()
+	return n > 0 && c.Req.ContentLength > n
+}
+
+// parseForm 解析表单，只返回请求体超过上限的错误，其余错误与 Request.FormValue 一样忽略
+func (c *Context) parseForm() error {
+	if c.Req.PostForm != nil {
+		return nil
+	}
+	// 非 multipart 请求的 ParseMultipartForm 只返回 ErrNotMultipart，所以先单独调用 ParseForm
+	err := c.Req.ParseForm()
+	if err == nil {
+		err = c.Req.ParseMultipartForm(32 << 20)
+	}
+	if errors.Is(err, ErrBodyTooLarge) {
+		return ErrBodyTooLarge
+	}
+	return nil
+}
+
+// abortBodyTooLarge 返回 413，并丢弃 handler 之后写出的内容
+func (c *Context) abortBodyTooLarge() {
+	if c.Written() {
+		c.Abort()
+		return
+	}
+	c.Error(ErrBodyTooLarge)
+	c.writer.discard = true
+}
+
+// MinThroughput 要求请求体在 grace 之后的平均速率不低于 minRate 字节/秒，否则返回 408 并断开连接
+func MinThroughput(minRate int64, grace time.Duration) HandlerFunc {
+	return func(c *Context) {
+		if c.Req.Body == nil || c.Req.Body == http.NoBody || minRate <= 0 {
+			c.Next()
+			return
+		}
+		rc := http.NewResponseController(c.Writer)
+		body := &throughputBody{
+			ReadCloser: c.Req.Body,
+			rc:         rc,
+			header:     c.Writer.Header(),
+			minRate:    minRate,
+			grace:      grace,
+			start:      time.Now(),
+		}
+		c.Req.Body = body
+		c.Next()
+		if !body.slow {
+			rc.SetReadDeadline(time.Time{})
+		}
+	}
+}
+
+type throughputBody struct {
+	io.ReadCloser
+	rc      *http.ResponseController
+	header  http.Header
+	minRate int64
+	grace   time.Duration
+	start   time.Time
+	read    int64
+	slow    bool
+}
+
+func (b *throughputBody) Read(p []byte) (int, error) {
+	if b.slow {
+		return 0, ErrSlowBody
+	}
+	// 再读到 1 个字节时平均速率仍不低于 minRate 的最晚时间
+	// 先除后乘，避免 read 很大时 Duration 溢出
+	want := b.read + 1
+	allowed := time.Duration(want/b.minRate)*time.Second + time.Duration(want%b.minRate)*time.Second/time.Duration(b.minRate)
+	deadline := b.start.Add(b.grace + allowed)
+	if time.Now().After(deadline) {
+		b.markSlow()
+		return 0, ErrSlowBody
+	}
+	b.rc.SetReadDeadline(deadline)
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	var ne interface{ Timeout() bool }
+	if err != nil && errors.As(err, &ne) && ne.Timeout() {
+		b.markSlow()
+		err = ErrSlowBody
+	}
+	return n, err
+}
+
+// markSlow 要求响应后关闭连接，避免 net/http 继续读取剩余的请求体
+func (b *throughputBody) markSlow() {
+	b.slow = true
+	b.header.Set("Connection", "close")
+}
@@ -0,0 +1,127 @@
+package gee
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type formReq struct {
+	Name string `form:"name"`
+}
+
+func newLimitEngine() *Engine {
+	r := New()
+	r.SetMaxBodySize(16)
+	r.POST("/form", func(c *Context) {
+		c.String(http.StatusOK, "name=%s", c.PostForm("name"))
+	})
+	r.POST("/bind", func(c *Context) {
+		var req formReq
+		if err := c.Bind(&req); err != nil {
+			c.Error(err)
+			return
+		}
+		c.String(http.StatusOK, "name=%s", req.Name)
+	})
+	return r
+}
+
+// postForm 发送表单，chunked 为 true 时不设置 Content-Length
+func postForm(r *Engine, path, body string, chunked bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if chunked {
+		req.ContentLength = -1
+	}
+	return serveRequest(r, req)
+}
+
+func TestMaxBodySize(t *testing.T) {
+	r := newLimitEngine()
+	large := "name=" + strings.Repeat("x", 32)
+	cases := []struct {
+		path, body string
+		chunked    bool
+		code       int
+		want       string
+	}{
+		{"/form", "name=gee", false, http.StatusOK, "name=gee"},
+		{"/form", "name=gee", true, http.StatusOK, "name=gee"},
+		{"/form", large, false, http.StatusRequestEntityTooLarge, ""},
+		{"/form", large, true, http.StatusRequestEntityTooLarge, ""},
+		{"/bind", "name=gee", true, http.StatusOK, "name=gee"},
+		{"/bind", large, true, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tc := range cases {
+		w := postForm(r, tc.path, tc.body, tc.chunked)
+		if w.Code != tc.code {
+			t.Fatalf("POST %s (chunked %v) = %d %q, want %d", tc.path, tc.chunked, w.Code, w.Body.String(), tc.code)
+		}
+		if tc.want != "" && w.Body.String() != tc.want {
+			t.Fatalf("POST %s (chunked %v) body = %q, want %q", tc.path, tc.chunked, w.Body.String(), tc.want)
+		}
+		if tc.code != http.StatusOK && strings.Contains(w.Body.String(), "name=") {
+			t.Fatalf("POST %s: handler output written after 413: %q", tc.path, w.Body.String())
+		}
+	}
+}
+
+// 已经读取了很多数据时计算截止时间不能溢出
+func TestThroughputDeadlineOverflow(t *testing.T) {
+	body := &throughputBody{
+		ReadCloser: io.NopCloser(strings.NewReader("data")),
+		rc:         http.NewResponseController(httptest.NewRecorder()),
+		header:     http.Header{},
+		minRate:    1,
+		start:      time.Now(),
+		read:       1 << 62,
+	}
+	if _, err := body.Read(make([]byte, 4)); err != nil {
+		t.Fatalf("Read = %v, want no error", err)
+	}
+}
+
+func TestMinThroughput(t *testing.T) {
+	r := New()
+	r.Use(MinThroughput(1000, 0))
+	r.POST("/upload", func(c *Context) {
+		data, err := io.ReadAll(c.Req.Body)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.String(http.StatusOK, "%d", len(data))
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/upload", "text/plain", strings.NewReader(strings.Repeat("x", 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("fast upload = %d", resp.StatusCode)
+	}
+
+	// 发送 10 个字节之后停顿，平均速率远低于 1000 字节/秒
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("0123456789"))
+		time.Sleep(300 * time.Millisecond)
+		pw.Write([]byte("0123456789"))
+		pw.Close()
+	}()
+	resp, err = http.Post(srv.URL+"/upload", "text/plain", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout || !resp.Close {
+		t.Fatalf("slow upload = %d (close %v), want 408 and Connection: close", resp.StatusCode, resp.Close)
+	}
+}
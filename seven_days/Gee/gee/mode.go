@@ -0,0 +1,64 @@
+package gee
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+)
+
+/*
+	运行模式：
+	debug   打印注册的路由、路由冲突警告，模板出错时把详细信息返回给客户端，默认模式
+	release 不打印调试信息，模板错误只记录日志
+	test    与 release 相同，另外 Default() 不安装 Logger，避免测试输出过多
+	启动时从环境变量 GEE_MODE 读取，也可以通过 SetMode 设置。
+*/
+
+const EnvGeeMode = "GEE_MODE"
+
+const (
+	DebugMode   = "debug"
+	ReleaseMode = "release"
+	TestMode    = "test"
+)
+
+var geeMode atomic.Value // string
+
+func init() {
+	SetMode(os.Getenv(EnvGeeMode))
+}
+
+// SetMode 设置运行模式，value 为空时使用 debug 模式
+func SetMode(value string) {
+	switch value {
+	case "":
+		value = DebugMode
+	case DebugMode, ReleaseMode, TestMode:
+	default:
+		panic("gee: unknown mode " + value + ", expect debug, release or test")
+	}
+	geeMode.Store(value)
+}
+
+// Mode 返回当前运行模式
+func Mode() string {
+	return geeMode.Load().(string)
+}
+
+// IsDebugging 判断是否处于 debug 模式
+func IsDebugging() bool {
+	return Mode() == DebugMode
+}
+
+func debugPrint(format string, values ...interface{}) {
+	if IsDebugging() {
+		log.Printf("[GEE-debug] "+format, values...)
+	}
+}
+
+func debugWarning(format string, values ...interface{}) {
+	if IsDebugging() {
+		log.Printf("[GEE-debug] [WARNING] %s", fmt.Sprintf(format, values...))
+	}
+}
@@ -0,0 +1,108 @@
+package gee
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// withMode 在 mode 下执行 fn，同时收集日志输出
+func withMode(mode string, fn func()) string {
+	old := Mode()
+	SetMode(mode)
+	defer SetMode(old)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	fn()
+	return buf.String()
+}
+
+// 子进程中检查 init 从 GEE_MODE 读取的模式
+func TestModeFromEnv(t *testing.T) {
+	if want := os.Getenv("GEE_TEST_EXPECT_MODE"); want != "" {
+		if Mode() != want {
+			t.Fatalf("Mode() = %q, want %q", Mode(), want)
+		}
+		return
+	}
+	for env, want := range map[string]string{"": DebugMode, "release": ReleaseMode, "test": TestMode} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestModeFromEnv$")
+		cmd.Env = append(os.Environ(), EnvGeeMode+"="+env, "GEE_TEST_EXPECT_MODE="+want)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("GEE_MODE=%q: %v\n%s", env, err, out)
+		}
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), EnvGeeMode+"=production")
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "unknown mode production") {
+		t.Fatalf("unknown GEE_MODE should panic at init: %v\n%s", err, out)
+	}
+}
+
+func TestSetModeUnknown(t *testing.T) {
+	old := Mode()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("SetMode with an unknown mode should panic")
+		}
+		if Mode() != old {
+			t.Fatalf("mode changed to %q after a failed SetMode", Mode())
+		}
+	}()
+	SetMode("production")
+}
+
+// test 模式下 Default 只安装 Recover
+func TestDefaultTestMode(t *testing.T) {
+	var r *Engine
+	out := withMode(TestMode, func() { r = Default() })
+	if len(r.middlewares) != 1 || out != "" {
+		t.Fatalf("Default() in test mode: %d middlewares, output %q", len(r.middlewares), out)
+	}
+	r.GET("/panic", func(c *Context) { panic("boom") })
+	withMode(TestMode, func() {
+		if w := serve(r, "GET", "/panic", nil); w.Code != http.StatusInternalServerError {
+			t.Fatalf("Recover not installed: %d", w.Code)
+		}
+	})
+
+	withMode(DebugMode, func() { r = Default() })
+	if len(r.middlewares) != 2 {
+		t.Fatalf("Default() in debug mode: %d middlewares, want Logger and Recover", len(r.middlewares))
+	}
+}
+
+// 路由冲突只在 debug 模式下打印警告
+func TestConflictWarnings(t *testing.T) {
+	register := func() {
+		r := New()
+		r.GET("/p/:lang", func(c *Context) {})
+		r.GET("/p/doc", func(c *Context) {})
+		r.GET("/static/*filepath", func(c *Context) {})
+		r.GET("/static/css/main.css", func(c *Context) {})
+		r.GET("/hello", func(c *Context) {})
+		r.GET("/hello", func(c *Context) {})
+	}
+
+	out := withMode(DebugMode, register)
+	for _, want := range []string{
+		"route GET /p/doc shadows previously registered /p/:lang",
+		"route GET /static/css/main.css is shadowed by /static/*filepath",
+		"route GET /hello is registered more than once",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("debug output missing %q:\n%s", want, out)
+		}
+	}
+	for _, mode := range []string{ReleaseMode, TestMode} {
+		if out := withMode(mode, register); out != "" {
+			t.Fatalf("%s mode should not print warnings:\n%s", mode, out)
+		}
+	}
+}
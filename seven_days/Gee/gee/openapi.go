@@ -0,0 +1,208 @@
+package gee
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*
+	OpenAPI 根据通过 RouterGroup.Endpoint 注册的路由生成 OpenAPI 3.0 文档：
+	路由中的 :name 转换为 {name}，请求结构体中 param/query/header tag 对应的字段转换为参数，
+	其余字段作为 JSON 请求体，响应类型作为 200 响应的 schema。
+*/
+
+// OpenAPI 返回 OpenAPI 3.0 文档，可以直接用 c.JSON 输出
+func (engine *Engine) OpenAPI(title, version string) H {
+	paths := H{}
+	for _, r := range engine.endpoints {
+		path, pathParams := openAPIPath(r.pattern)
+		item, ok := paths[path].(H)
+		if !ok {
+			item = H{}
+			paths[path] = item
+		}
+		op := H{
+			"responses": H{
+				"200": H{
+					"description": "OK",
+					"content":     H{"application/json": H{"schema": schemaOf(r.endpoint.Response)}},
+				},
+				"default": H{
+					"description": "Error",
+					"content": H{"application/json": H{"schema": H{
+						"type":       "object",
+						"properties": H{"error": H{"type": "string"}},
+					}}},
+				},
+			},
+		}
+		if r.endpoint.Summary != "" {
+			op["summary"] = r.endpoint.Summary
+		}
+		if len(r.endpoint.Tags) > 0 {
+			op["tags"] = r.endpoint.Tags
+		}
+		params, body := requestSchema(r.endpoint.Request, pathParams)
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if body != nil && r.method != http.MethodGet && r.method != http.MethodHead {
+			op["requestBody"] = H{"content": H{"application/json": H{"schema": body}}}
+		}
+		item[strings.ToLower(r.method)] = op
+	}
+	return H{
+		"openapi": "3.0.3",
+		"info":    H{"title": title, "version": version},
+		"paths":   paths,
+	}
+}
+
+// ServeOpenAPI 在 pattern 上提供 OpenAPI 文档
+func (group *RouterGroup) ServeOpenAPI(pattern, title, version string) {
+	engine := group.engine
+	group.GET(pattern, func(c *Context) {
+		c.JSON(http.StatusOK, engine.OpenAPI(title, version))
+	})
+}
+
+// openAPIPath 把 /user/:id/*path 转换为 /user/{id}/{path}
+func openAPIPath(pattern string) (string, []string) {
+	var params []string
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		if part != "" && (part[0] == ':' || part[0] == '*') {
+			params = append(params, part[1:])
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+func requestSchema(t reflect.Type, pathParams []string) ([]H, H) {
+	var params []H
+	properties := H{}
+	var required []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				walk(field.Type)
+				continue
+			}
+			in, name := "", ""
+			for _, source := range bindSources {
+				if v := field.Tag.Get(source); v != "" && v != "-" {
+					in, name = source, v
+					break
+				}
+			}
+			isRequired := field.Tag.Get("binding") == "required"
+			switch in {
+			case "param":
+				params = append(params, H{"name": name, "in": "path", "required": true, "schema": schemaOf(field.Type)})
+			case "query", "header":
+				params = append(params, H{"name": name, "in": in, "required": isRequired, "schema": schemaOf(field.Type)})
+			case "form":
+				continue
+			default:
+				jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+				if jsonName == "-" {
+					continue
+				}
+				if jsonName == "" {
+					jsonName = field.Name
+				}
+				properties[jsonName] = schemaOf(field.Type)
+				if isRequired {
+					required = append(required, jsonName)
+				}
+			}
+		}
+	}
+	walk(t)
+	// 路由中声明但请求结构体中没有对应字段的参数
+	for _, name := range pathParams {
+		found := false
+		for _, p := range params {
+			if p["in"] == "path" && p["name"] == name {
+				found = true
+			}
+		}
+		if !found {
+			params = append(params, H{"name": name, "in": "path", "required": true, "schema": H{"type": "string"}})
+		}
+	}
+	if len(properties) == 0 {
+		return params, nil
+	}
+	body := H{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		body["required"] = required
+	}
+	return params, body
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf 通过反射生成 JSON schema
+func schemaOf(t reflect.Type) H {
+	return schemaOfSeen(t, map[reflect.Type]bool{})
+}
+
+// seen 记录正在展开的结构体，遇到递归类型时不再展开
+func schemaOfSeen(t reflect.Type, seen map[reflect.Type]bool) H {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return H{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return H{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return H{"type": "number"}
+	case reflect.String:
+		return H{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return H{"type": "string", "format": "byte"}
+		}
+		return H{"type": "array", "items": schemaOfSeen(t.Elem(), seen)}
+	case reflect.Map:
+		return H{"type": "object", "additionalProperties": schemaOfSeen(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return H{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		properties := H{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = schemaOfSeen(field.Type, seen)
+		}
+		return H{"type": "object", "properties": properties}
+	}
+	return H{}
+}
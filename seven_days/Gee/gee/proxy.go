@@ -0,0 +1,255 @@
+package gee
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	RouterGroup.Proxy 把 prefix 下的所有请求转发给后端服务，转发发生在路由 handler 中，
+	所以分组上的中间件（Logger、鉴权等）会先执行。
+	1、路径改写：默认去掉分组前缀和 prefix，再拼接到 target 的路径后面，也可以通过 Rewrite 自定义。
+	2、请求头：httputil.ReverseProxy 会追加 X-Forwarded-For，这里再补充 X-Forwarded-Host 和 X-Forwarded-Proto，
+	   客户端传来的 X-Forwarded-Proto、X-Forwarded-For、Forwarded 和 X-Real-IP 只有在对端是受信任的代理时才会保留，
+	   否则 X-Forwarded-Proto 被覆盖，其余的被删除，后端看到的转发链只从本节点开始，见 SetTrustedProxies。
+	3、负载均衡：RoundRobin 轮询，LeastConn 选择当前活跃连接最少的后端。
+	4、被动健康检查：后端连续失败 MaxFails 次后，在 FailTimeout 内不再被选中。
+*/
+
+// BalanceMode 负载均衡模式
+type BalanceMode int
+
+const (
+	RoundRobin BalanceMode = iota // 轮询
+	LeastConn                     // 最少连接
+)
+
+// 对所有方法注册代理路由
+var proxyMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// Proxy 反向代理，创建后可以修改导出字段进行配置
+type Proxy struct {
+	Balance     BalanceMode
+	MaxFails    int           // 连续失败多少次后摘除，默认 3
+	FailTimeout time.Duration // 摘除时长，默认 10s
+	// Rewrite 改写转发的路径，参数是去掉前缀之后的路径
+	Rewrite func(path string) string
+	// ModifyRequest 在请求发往后端之前调用
+	ModifyRequest func(req *http.Request)
+	// ModifyResponse 在收到后端响应之后调用，返回错误时按 502 处理
+	ModifyResponse func(resp *http.Response) error
+	// Transport 为空时使用 http.DefaultTransport
+	Transport http.RoundTripper
+
+	prefix  string
+	targets []*proxyTarget
+	next    uint32
+}
+
+type proxyTarget struct {
+	url          *url.URL
+	proxy        *httputil.ReverseProxy
+	active       int64 // 活跃连接数
+	mu           sync.Mutex
+	fails        int
+	ejectedUntil time.Time
+}
+
+func (t *proxyTarget) healthy(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return now.After(t.ejectedUntil)
+}
+
+// Proxy 注册 prefix 下的代理路由，targets 形如 http://10.0.0.1:8080/v1
+func (group *RouterGroup) Proxy(prefix string, targets ...string) *Proxy {
+	if len(targets) == 0 {
+		panic("gee: Proxy requires at least one target")
+	}
+	p := &Proxy{
+		MaxFails:    3,
+		FailTimeout: 10 * time.Second,
+		prefix:      path.Join("/", group.prefix, prefix),
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			panic("gee: invalid proxy target " + target)
+		}
+		p.targets = append(p.targets, p.newTarget(u))
+	}
+	handler := p.handle
+	for _, method := range proxyMethods {
+		group.addRoute(method, prefix, handler)
+		group.addRoute(method, path.Join(prefix, "/*proxypath"), handler)
+	}
+	return p
+}
+
+func (p *Proxy) newTarget(u *url.URL) *proxyTarget {
+	t := &proxyTarget{url: u}
+	t.proxy = &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			p.direct(t, req)
+		},
+		Transport: proxyTransport{p},
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode >= http.StatusInternalServerError {
+				p.fail(t)
+			} else {
+				p.succeed(t)
+			}
+			if p.ModifyResponse != nil {
+				return p.ModifyResponse(resp)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Printf("[Proxy] %s %s -> %s: %v", req.Method, req.URL.Path, t.url.Host, err)
+			p.fail(t)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	return t
+}
+
+func (p *Proxy) direct(t *proxyTarget, req *http.Request) {
+	rest := strings.TrimPrefix(req.URL.Path, p.prefix)
+	if rest == "" || rest[0] != '/' {
+		rest = "/" + rest
+	}
+	if p.Rewrite != nil {
+		rest = p.Rewrite(rest)
+	}
+	req.URL.Scheme = t.url.Scheme
+	req.URL.Host = t.url.Host
+	req.URL.Path = singleJoiningSlash(t.url.Path, rest)
+	req.URL.RawPath = ""
+	if t.url.RawQuery != "" {
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = t.url.RawQuery
+		} else {
+			req.URL.RawQuery = t.url.RawQuery + "&" + req.URL.RawQuery
+		}
+	}
+	req.Header.Set("X-Forwarded-Host", req.Host)
+	if fwd, ok := req.Context().Value(forwardedKey{}).(forwarded); ok {
+		req.Header.Set("X-Forwarded-Proto", fwd.proto)
+		if !fwd.trusted { // 客户端伪造的转发链不能传给后端
+			req.Header.Del("X-Forwarded-For")
+			req.Header.Del("Forwarded")
+			req.Header.Del("X-Real-IP")
+		}
+	}
+	req.Host = t.url.Host
+	if p.ModifyRequest != nil {
+		p.ModifyRequest(req)
+	}
+}
+
+// forwardedKey handle 通过请求的 context 把转发信息传给 direct
+type forwardedKey struct{}
+
+type forwarded struct {
+	proto   string // 转发给后端的协议
+	trusted bool   // 对端是受信任的代理，保留它传来的转发链
+}
+
+// forwardedInfo 不受信任的客户端传来的 X-Forwarded-Proto 会被覆盖
+func forwardedInfo(c *Context) forwarded {
+	fwd := forwarded{proto: "http", trusted: c.fromTrustedProxy()}
+	if c.Req.TLS != nil {
+		fwd.proto = "https"
+	} else if proto := c.Req.Header.Get("X-Forwarded-Proto"); proto != "" && fwd.trusted {
+		fwd.proto = proto
+	}
+	return fwd
+}
+
+// proxyTransport 在请求时读取 Proxy.Transport，允许创建 Proxy 之后再设置
+type proxyTransport struct {
+	p *Proxy
+}
+
+func (t proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.p.Transport != nil {
+		return t.p.Transport.RoundTrip(req)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// pick 选择一个健康的后端，全部被摘除时退化为在所有后端中选择
+func (p *Proxy) pick() *proxyTarget {
+	now := time.Now()
+	candidates := make([]*proxyTarget, 0, len(p.targets))
+	for _, t := range p.targets {
+		if t.healthy(now) {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = p.targets
+	}
+	switch p.Balance {
+	case LeastConn:
+		best := candidates[0]
+		for _, t := range candidates[1:] {
+			if atomic.LoadInt64(&t.active) < atomic.LoadInt64(&best.active) {
+				best = t
+			}
+		}
+		return best
+	default:
+		n := atomic.AddUint32(&p.next, 1)
+		return candidates[int(n-1)%len(candidates)]
+	}
+}
+
+func (p *Proxy) fail(t *proxyTarget) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fails++
+	if p.MaxFails > 0 && t.fails >= p.MaxFails {
+		t.fails = 0
+		t.ejectedUntil = time.Now().Add(p.FailTimeout)
+		log.Printf("[Proxy] eject %s for %v", t.url.Host, p.FailTimeout)
+	}
+}
+
+func (p *Proxy) succeed(t *proxyTarget) {
+	t.mu.Lock()
+	t.fails = 0
+	t.mu.Unlock()
+}
+
+func (p *Proxy) handle(c *Context) {
+	t := p.pick()
+	atomic.AddInt64(&t.active, 1)
+	defer atomic.AddInt64(&t.active, -1)
+	req := c.Req.WithContext(context.WithValue(c.Req.Context(), forwardedKey{}, forwardedInfo(c)))
+	t.proxy.ServeHTTP(c.Writer, req)
+	c.StatusCode = c.writer.status
+}
@@ -0,0 +1,109 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newBackend 返回请求的路径、X-Forwarded-Proto 和后端名字
+func newBackend(t *testing.T, name string, code int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(code)
+		fmt.Fprintf(w, "%s %s?%s %s %s", name, req.URL.Path, req.URL.RawQuery,
+			req.Header.Get("X-Forwarded-Proto"), req.Header.Get("X-Forwarded-Host"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProxy(t *testing.T) {
+	a := newBackend(t, "a", http.StatusOK)
+	b := newBackend(t, "b", http.StatusOK)
+	r := New()
+	var seen []string
+	api := r.Group("/api")
+	api.Use(func(c *Context) {
+		seen = append(seen, c.Req.URL.Path)
+		c.Next()
+	})
+	api.Proxy("/svc", a.URL+"/v1", b.URL+"/v1")
+
+	want := []string{
+		"a /v1/users?id=1 http example.com",
+		"b /v1/users?id=1 http example.com",
+		"a /v1/users?id=1 http example.com",
+	}
+	for i, body := range want {
+		if w := serveFrom(r, "192.0.2.1:1234", "GET", "/api/svc/users?id=1", nil); w.Code != http.StatusOK || w.Body.String() != body {
+			t.Fatalf("request %d = %d %q, want %q", i, w.Code, w.Body.String(), body)
+		}
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("group middleware ran %d times, want %d", len(seen), len(want))
+	}
+}
+
+// 只有受信任的代理传来的 X-Forwarded-Proto 才会转发给后端
+func TestProxyForwardedProto(t *testing.T) {
+	backend := newBackend(t, "a", http.StatusOK)
+	r := New()
+	r.Proxy("/svc", backend.URL)
+	r.Proxy("/rewrite", backend.URL).Rewrite = func(path string) string { return "/v2" + path }
+	if err := r.SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	https := http.Header{"X-Forwarded-Proto": {"https"}}
+
+	if w := serveFrom(r, "203.0.113.7:1234", "GET", "/svc/x", https); w.Body.String() != "a /x? http example.com" {
+		t.Fatalf("untrusted client: %q", w.Body.String())
+	}
+	if w := serveFrom(r, "10.0.0.1:1234", "GET", "/svc/x", https); w.Body.String() != "a /x? https example.com" {
+		t.Fatalf("trusted proxy: %q", w.Body.String())
+	}
+	if w := serveFrom(r, "10.0.0.1:1234", "GET", "/rewrite/x", nil); w.Body.String() != "a /v2/x? http example.com" {
+		t.Fatalf("rewrite: %q", w.Body.String())
+	}
+}
+
+// 不受信任的客户端伪造的 X-Forwarded-For、Forwarded 和 X-Real-IP 不会传给后端
+func TestProxyForwardedFor(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s", req.Header.Get("X-Forwarded-For"), req.Header.Get("Forwarded"), req.Header.Get("X-Real-IP"))
+	}))
+	t.Cleanup(backend.Close)
+	r := New()
+	r.Proxy("/svc", backend.URL)
+	if err := r.SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	spoofed := http.Header{
+		"X-Forwarded-For": {"1.2.3.4"},
+		"Forwarded":       {"for=1.2.3.4"},
+		"X-Real-Ip":       {"1.2.3.4"},
+	}
+
+	if w := serveFrom(r, "203.0.113.7:1234", "GET", "/svc", spoofed); w.Body.String() != "203.0.113.7||" {
+		t.Fatalf("untrusted client: %q", w.Body.String())
+	}
+	if w := serveFrom(r, "10.0.0.1:1234", "GET", "/svc", spoofed); w.Body.String() != "1.2.3.4, 10.0.0.1|for=1.2.3.4|1.2.3.4" {
+		t.Fatalf("trusted proxy: %q", w.Body.String())
+	}
+}
+
+// 连续失败 MaxFails 次的后端被摘除，请求全部转发给健康的后端
+func TestProxyEject(t *testing.T) {
+	bad := newBackend(t, "bad", http.StatusInternalServerError)
+	good := newBackend(t, "good", http.StatusOK)
+	r := New()
+	p := r.Proxy("/svc", bad.URL, good.URL)
+	p.MaxFails = 1
+
+	serveFrom(r, "192.0.2.1:1234", "GET", "/svc", nil) // bad 失败一次，被摘除
+	for i := 0; i < 4; i++ {
+		if w := serveFrom(r, "192.0.2.1:1234", "GET", "/svc", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d went to the ejected backend: %d %q", i, w.Code, w.Body.String())
+		}
+	}
+}
@@ -3,7 +3,6 @@ package gee
 import (
 	"fmt"
 	"log"
-	"net/http"
 	"runtime"
 	"strings"
 )
@@ -34,14 +33,17 @@ func trace(message string) string {
 	return str.String()
 }
 
+// Recover 捕获 panic，并以 *PanicError 交给分组的 ErrorHandler，默认返回 500 JSON
 func Recover() HandlerFunc {
 	return func(ctx *Context) {
 		defer func() {
 			if err := recover(); err != nil {
 				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
-				ctx.String(http.StatusInternalServerError, "Internal Server Error")
+				stack := trace(message)
+				log.Printf("%s\n\n", stack)
+				ctx.Error(&PanicError{Value: err, Stack: stack})
 			}
 		}()
+		ctx.Next()
 	}
 }
@@ -0,0 +1,99 @@
+package gee
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseWriter 包装 http.ResponseWriter，
+// 在第一次写出响应头之前依次执行 before 回调，
+// 这样中间件可以在 handler 返回之前补充 Set-Cookie 等响应头。
+type responseWriter struct {
+	http.ResponseWriter
+	before      []func()
+	wroteHeader bool
+	status      int
+	discard     bool // 错误响应已经写出，丢弃之后的写入
+}
+
+// writtenWriter 由包装 ResponseWriter 的中间件实现，报告响应是否已经写出或被缓存
+type writtenWriter interface {
+	Written() bool
+}
+
+// written 判断 w 是否已经写出响应，w 没有实现 writtenWriter 时沿着 Unwrap 向下查找
+func written(w http.ResponseWriter) bool {
+	for w != nil {
+		if ww, ok := w.(writtenWriter); ok {
+			return ww.Written()
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
+	return false
+}
+
+// Written 实现 writtenWriter
+func (w *responseWriter) Written() bool {
+	return w.wroteHeader
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	for _, fn := range w.before {
+		fn()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.discard {
+		return len(b), nil
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush 实现 http.Flusher
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 实现 http.Hijacker，供 websocket 等协议升级使用
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gee: ResponseWriter does not implement http.Hijacker")
+	}
+	w.wroteHeader = true
+	return h.Hijack()
+}
+
+// Push 实现 http.Pusher，只有 HTTP/2 连接支持
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap 供 http.ResponseController 取得底层 ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
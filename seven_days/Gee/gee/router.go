@@ -1,22 +1,80 @@
 package gee
 
 import (
-	"net/http"
+	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+/*
+	路由表采用 copy-on-write：
+	读（ServeHTTP）通过 atomic.Value 无锁地拿到当前路由表的快照，
+	写（addRoute/removeRoute/replaceHandler）在 mu 保护下复制一份新表，修改后整体替换。
+	因此服务运行期间也可以安全地增删路由，正在处理的请求继续使用旧的快照。
+	第一个请求到来之前没有读者，addRoute 直接修改当前的表，启动时注册 n 条路由不需要复制 n 次。
+	分组和中间件由 Engine.mu 保护，分组的其他配置（模板、请求体上限、超时等）仍然需要在服务启动前设置。
+*/
 type router struct {
+	mu      sync.Mutex   // 串行化写操作
+	table   atomic.Value // *routeTable
+	serving uint32       // 处理过请求之后为 1，此后的修改都要 copy-on-write
+}
+
+type routeTable struct {
 	roots    map[string]*node
 	handlers map[string]HandlerFunc
+	patterns map[string][]string // 每个 method 按注册顺序保存的 pattern，用于重建前缀树
 }
 
 // roots key eg, roots['GET'] roots['POST']
 // handlers key eg, handlers['GET-/p/:lang/doc'], handlers['POST-/p/book']
 func newRouter() *router {
-	return &router{
+	r := &router{}
+	r.table.Store(&routeTable{
 		roots:    make(map[string]*node),
 		handlers: make(map[string]HandlerFunc),
+		patterns: make(map[string][]string),
+	})
+	return r
+}
+
+func (r *router) load() *routeTable {
+	return r.table.Load().(*routeTable)
+}
+
+// clone 浅拷贝路由表，前缀树只在被修改的 method 上重建，其余共享
+func (t *routeTable) clone() *routeTable {
+	nt := &routeTable{
+		roots:    make(map[string]*node, len(t.roots)),
+		handlers: make(map[string]HandlerFunc, len(t.handlers)),
+		patterns: make(map[string][]string, len(t.patterns)),
+	}
+	for k, v := range t.roots {
+		nt.roots[k] = v
+	}
+	for k, v := range t.handlers {
+		nt.handlers[k] = v
+	}
+	for k, v := range t.patterns {
+		nt.patterns[k] = v
 	}
+	return nt
+}
+
+// rebuild 按注册顺序重新构建 method 对应的前缀树
+func (t *routeTable) rebuild(method string) {
+	patterns := t.patterns[method]
+	if len(patterns) == 0 {
+		delete(t.roots, method)
+		delete(t.patterns, method)
+		return
+	}
+	root := &node{}
+	for _, pattern := range patterns {
+		root.insert(pattern, parsePattern(pattern), 0)
+	}
+	t.roots[method] = root
 }
 
 // Only one * is allowed
@@ -36,15 +94,94 @@ func parsePattern(pattern string) []string {
 }
 
 func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
-	parts := parsePattern(pattern)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	serving := atomic.LoadUint32(&r.serving) == 1
+	t := r.load()
+	if serving {
+		t = t.clone()
+	}
+	key := method + "-" + pattern
+	if _, ok := t.handlers[key]; !ok {
+		patterns := t.patterns[method]
+		if serving {
+			// 重新分配底层数组，避免与旧快照共享
+			t.patterns[method] = append(patterns[:len(patterns):len(patterns)], pattern)
+			t.rebuild(method)
+		} else {
+			// 与 rebuild 的结果相同：按注册顺序插入前缀树
+			t.patterns[method] = append(patterns, pattern)
+			root, ok := t.roots[method]
+			if !ok {
+				root = &node{}
+				t.roots[method] = root
+			}
+			root.insert(pattern, parsePattern(pattern), 0)
+		}
+		if IsDebugging() {
+			t.checkConflicts(method, pattern)
+		}
+	} else {
+		debugWarning("route %s %s is registered more than once, the previous handler is replaced", method, pattern)
+	}
+	t.handlers[key] = handler
+	r.table.Store(t)
+}
 
+/*
+	前缀树按注册顺序匹配，一个 pattern 在树中查找到的节点如果属于另一个 pattern，
+	说明两者冲突：例如先注册 /p/:lang 再注册 /p/doc，两者落在同一个节点上，
+	或者 /static/*filepath 之后注册的 /static/css/main.css 永远不会被匹配到。
+*/
+func (t *routeTable) checkConflicts(method string, pattern string) {
+	root := t.roots[method]
+	for _, p := range t.patterns[method] {
+		n := root.search(parsePattern(p), 0)
+		switch {
+		case n == nil || n.pattern == p:
+			continue
+		case p == pattern:
+			debugWarning("route %s %s is shadowed by %s", method, pattern, n.pattern)
+		case n.pattern == pattern:
+			debugWarning("route %s %s shadows previously registered %s", method, pattern, p)
+		}
+	}
+}
+
+// removeRoute 删除路由，路由不存在时返回 false
+func (r *router) removeRoute(method string, pattern string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	key := method + "-" + pattern
-	_, ok := r.roots[method]
-	if !ok {
-		r.roots[method] = &node{} // 创建一个路由树
+	if _, ok := r.load().handlers[key]; !ok {
+		return false
+	}
+	t := r.load().clone()
+	delete(t.handlers, key)
+	patterns := make([]string, 0, len(t.patterns[method]))
+	for _, p := range t.patterns[method] {
+		if p != pattern {
+			patterns = append(patterns, p)
+		}
 	}
-	r.roots[method].insert(pattern, parts, 0)
-	r.handlers[key] = handler
+	t.patterns[method] = patterns
+	t.rebuild(method)
+	r.table.Store(t)
+	return true
+}
+
+// replaceHandler 替换已存在路由的 handler，前缀树不变
+func (r *router) replaceHandler(method string, pattern string, handler HandlerFunc) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := method + "-" + pattern
+	if _, ok := r.load().handlers[key]; !ok {
+		return false
+	}
+	t := r.load().clone()
+	t.handlers[key] = handler
+	r.table.Store(t)
+	return true
 }
 
 /*
@@ -52,10 +189,10 @@ func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
 	/static/css/geektutu.css匹配到/static/*filepath，
 	解析结果为{filepath: "css/geektutu.css"}。
 */
-func (r *router) getRoute(method string, path string) (*node, map[string]string) {
+func (t *routeTable) getRoute(method string, path string) (*node, map[string]string) {
 	searchParts := parsePattern(path)
 	params := make(map[string]string)
-	root, ok := r.roots[method]
+	root, ok := t.roots[method]
 	if !ok {
 		return nil, nil
 	}
@@ -78,15 +215,30 @@ func (r *router) getRoute(method string, path string) (*node, map[string]string)
 	return nil, nil
 }
 
+// startServing 标记路由表已经有读者，等待正在进行的原地修改完成
+func (r *router) startServing() {
+	if atomic.LoadUint32(&r.serving) == 0 {
+		r.mu.Lock()
+		atomic.StoreUint32(&r.serving, 1)
+		r.mu.Unlock()
+	}
+}
+
 func (r *router) handle(c *Context) {
-	n, params := r.getRoute(c.Method, c.Path) // 找到对应路由的handler
-	if n != nil {
+	r.startServing()
+	t := r.load()                             // 同一个请求始终使用同一份快照
+	n, params := t.getRoute(c.Method, c.Path) // 找到对应路由的handler
+	if c.bodyTooLarge() {
+		c.handlers = append(c.handlers, func(c *Context) {
+			c.Error(ErrBodyTooLarge)
+		})
+	} else if n != nil {
 		c.Params = params
 		key := c.Method + "-" + n.pattern
-		c.handlers = append(c.handlers, r.handlers[key]) // 具体执行函数的时候在 c.Next()中
+		c.handlers = append(c.handlers, t.handlers[key]) // 具体执行函数的时候在 c.Next()中
 	} else {
 		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+			c.Error(fmt.Errorf("404 NOT FOUND: %s: %w", c.Path, ErrNotFound))
 		})
 	}
 	c.Next()
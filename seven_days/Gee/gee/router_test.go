@@ -0,0 +1,141 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func newTestEngine() *Engine {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "index")
+	})
+	r.GET("/hello/:name", func(c *Context) {
+		c.String(http.StatusOK, "hello %s", c.Param("name"))
+	})
+	return r
+}
+
+func TestRemoveRoute(t *testing.T) {
+	r := newTestEngine()
+	if w := serve(r, "GET", "/hello/geektutu", nil); w.Code != http.StatusOK || w.Body.String() != "hello geektutu" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if !r.RemoveRoute("GET", "/hello/:name") {
+		t.Fatal("remove existing route failed")
+	}
+	if r.RemoveRoute("GET", "/hello/:name") {
+		t.Fatal("remove missing route should return false")
+	}
+	if w := serve(r, "GET", "/hello/geektutu", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("removed route should be 404, got %d", w.Code)
+	}
+	if w := serve(r, "GET", "/", nil); w.Code != http.StatusOK {
+		t.Fatalf("other routes should be kept, got %d", w.Code)
+	}
+}
+
+func TestReplaceHandler(t *testing.T) {
+	r := newTestEngine()
+	v1 := r.Group("/v1")
+	v1.GET("/flag", func(c *Context) { c.String(http.StatusOK, "old") })
+	if !v1.ReplaceHandler("GET", "/flag", func(c *Context) { c.String(http.StatusOK, "new") }) {
+		t.Fatal("replace existing route failed")
+	}
+	if w := serve(r, "GET", "/v1/flag", nil); w.Body.String() != "new" {
+		t.Fatalf("expect new handler, got %q", w.Body.String())
+	}
+	if v1.ReplaceHandler("GET", "/missing", func(c *Context) {}) {
+		t.Fatal("replace missing route should return false")
+	}
+}
+
+// go test -race 检查路由变更与 ServeHTTP 并发执行
+func TestConcurrentRouteChanges(t *testing.T) {
+	r := newTestEngine()
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if w := serve(r, "GET", "/hello/gee", nil); w.Code != http.StatusOK {
+					t.Errorf("stable route returned %d", w.Code)
+					return
+				}
+				serve(r, "GET", "/flag/3", nil)
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		pattern := fmt.Sprintf("/flag/%d", i%5)
+		r.AddRoute("GET", pattern, func(c *Context) { c.String(http.StatusOK, "on") })
+		r.ReplaceHandler("GET", pattern, func(c *Context) { c.String(http.StatusOK, "replaced") })
+		r.RemoveRoute("GET", pattern)
+		r.Group(pattern).Use(func(c *Context) { c.Next() })
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// 第一个请求之前原地修改路由表，之后每次修改都替换成新的快照
+func TestAddRouteBeforeServing(t *testing.T) {
+	r := New()
+	before := r.router.load()
+	for i := 0; i < 100; i++ {
+		r.GET(fmt.Sprintf("/r/%d", i), func(c *Context) { c.String(http.StatusOK, c.Path) })
+	}
+	if r.router.load() != before {
+		t.Fatal("routes registered before serving should not copy the table")
+	}
+	if w := serve(r, "GET", "/r/42", nil); w.Body.String() != "/r/42" {
+		t.Fatalf("GET /r/42 = %q", w.Body.String())
+	}
+	r.GET("/late", func(c *Context) { c.String(http.StatusOK, "late") })
+	if r.router.load() == before {
+		t.Fatal("routes registered while serving must not modify the old snapshot")
+	}
+	if _, ok := before.handlers["GET-/late"]; ok {
+		t.Fatal("old snapshot was modified")
+	}
+	if w := serve(r, "GET", "/late", nil); w.Body.String() != "late" {
+		t.Fatalf("GET /late = %q", w.Body.String())
+	}
+}
+
+// 分组按路径段匹配，/admin 的中间件和配置不作用于 /administrator
+func TestGroupMatch(t *testing.T) {
+	r := New()
+	admin := r.Group("/admin")
+	admin.Use(func(c *Context) {
+		c.SetHeader("X-Admin", "1")
+		c.Next()
+	})
+	admin.SetMaxBodySize(1)
+	admin.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "admin")
+	})
+	admin.GET("/users", func(c *Context) {
+		c.String(http.StatusOK, "users")
+	})
+	r.GET("/administrator", func(c *Context) {
+		c.String(http.StatusOK, "%d", c.group.bodyLimit())
+	})
+
+	for path, want := range map[string]string{"/admin": "1", "/admin/users": "1", "/administrator": ""} {
+		if w := serve(r, "GET", path, nil); w.Header().Get("X-Admin") != want {
+			t.Fatalf("GET %s: X-Admin = %q, want %q", path, w.Header().Get("X-Admin"), want)
+		}
+	}
+	if w := serve(r, "GET", "/administrator", nil); w.Body.String() != "0" {
+		t.Fatalf("/administrator uses the /admin body limit: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,85 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// SecureConfig 安全响应头的配置，字段为空（或 0、false）时删除对应的响应头
+type SecureConfig struct {
+	HSTSMaxAge            int    // Strict-Transport-Security 的 max-age，单位秒，只对 HTTPS 请求生效
+	HSTSIncludeSubdomains bool   // 追加 includeSubDomains
+	HSTSPreload           bool   // 追加 preload
+	ContentSecurityPolicy string // Content-Security-Policy
+	FrameOptions          string // X-Frame-Options，DENY 或 SAMEORIGIN
+	ContentTypeNosniff    bool   // X-Content-Type-Options: nosniff
+	ReferrerPolicy        string // Referrer-Policy
+}
+
+var DefaultSecureConfig = SecureConfig{
+	HSTSMaxAge:            31536000,
+	HSTSIncludeSubdomains: true,
+	ContentSecurityPolicy: "default-src 'self'",
+	FrameOptions:          "DENY",
+	ContentTypeNosniff:    true,
+	ReferrerPolicy:        "strict-origin-when-cross-origin",
+}
+
+/*
+	分组中间件按照父分组到子分组的顺序执行，
+	因此在子分组上再 Use(SecureHeaders(cfg)) 会整体覆盖父分组设置的响应头。
+	例如后台页面需要被同源页面嵌入：
+		cfg := gee.DefaultSecureConfig
+		cfg.FrameOptions = "SAMEORIGIN"
+		admin.Use(gee.SecureHeaders(cfg))
+*/
+
+// SecureHeaders 设置常用的安全响应头
+func SecureHeaders(config ...SecureConfig) HandlerFunc {
+	cfg := DefaultSecureConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	var hsts string
+	if cfg.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", cfg.HSTSMaxAge)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	var nosniff string
+	if cfg.ContentTypeNosniff {
+		nosniff = "nosniff"
+	}
+	return func(c *Context) {
+		h := c.Writer.Header()
+		if c.IsHTTPS() {
+			setOrDel(h, "Strict-Transport-Security", hsts)
+		}
+		setOrDel(h, "Content-Security-Policy", cfg.ContentSecurityPolicy)
+		setOrDel(h, "X-Frame-Options", cfg.FrameOptions)
+		setOrDel(h, "X-Content-Type-Options", nosniff)
+		setOrDel(h, "Referrer-Policy", cfg.ReferrerPolicy)
+		c.Next()
+	}
+}
+
+func setOrDel(h http.Header, key, value string) {
+	if value == "" {
+		h.Del(key)
+		return
+	}
+	h.Set(key, value)
+}
+
+// IsHTTPS 判断客户端是否通过 HTTPS 访问，
+// X-Forwarded-Proto 只有在 RemoteAddr 属于受信任的代理时才会被采用，见 SetTrustedProxies
+func (c *Context) IsHTTPS() bool {
+	if c.Req.TLS != nil {
+		return true
+	}
+	return c.fromTrustedProxy() && c.Req.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,462 @@
+package gee
+
+import (
+	"GeeCache/geecache"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+	会话由三部分组成：
+	1、Session 是请求作用域的会话对象，handler 通过 c.Session() 取得，支持 Get/Set/Delete/Flash。
+	2、Store 负责会话的读取与保存，CookieStore 把数据整个放在 cookie 里，MemoryStore 和 GeeCacheSessionStore 只在 cookie 里放会话 ID。
+	3、Sessions 中间件在请求开始时加载会话，在响应头写出前自动保存被修改过的会话。
+	会话的值使用 encoding/gob 序列化，自定义类型需要先调用 gob.Register 注册。
+*/
+
+const (
+	sessionContextKey = "gee/session"
+	flashesKey        = "_flash"
+)
+
+var ErrSessionNotFound = errors.New("gee: session not found")
+
+func init() {
+	gob.Register([]interface{}{})
+}
+
+// SessionOptions 对应会话 cookie 的属性，MaxAge < 0 表示删除会话
+type SessionOptions struct {
+	Path     string
+	Domain   string
+	MaxAge   int // 秒
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// DefaultSessionOptions 七天有效，仅限 HTTP 访问
+var DefaultSessionOptions = SessionOptions{
+	Path:     "/",
+	MaxAge:   86400 * 7,
+	HttpOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+func (o SessionOptions) cookie(name, value string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     o.Path,
+		Domain:   o.Domain,
+		MaxAge:   o.MaxAge,
+		Secure:   o.Secure,
+		HttpOnly: o.HttpOnly,
+		SameSite: o.SameSite,
+	}
+	if o.MaxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(o.MaxAge) * time.Second)
+	} else if o.MaxAge < 0 {
+		cookie.Expires = time.Unix(1, 0)
+	}
+	return cookie
+}
+
+// Session 请求作用域的会话
+type Session struct {
+	ID      string
+	Values  map[string]interface{}
+	IsNew   bool
+	Options SessionOptions
+	name    string
+	store   Store
+	dirty   bool
+}
+
+// NewSession 创建一个空会话，供 Store 的实现使用
+func NewSession(store Store, name string) *Session {
+	return &Session{
+		Values:  make(map[string]interface{}),
+		IsNew:   true,
+		Options: DefaultSessionOptions,
+		name:    name,
+		store:   store,
+	}
+}
+
+// Name 返回会话 cookie 的名称
+func (s *Session) Name() string {
+	return s.name
+}
+
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+func (s *Session) Set(key string, value interface{}) {
+	s.Values[key] = value
+	s.dirty = true
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.dirty = true
+}
+
+// Clear 删除会话中的所有值
+func (s *Session) Clear() {
+	s.Values = make(map[string]interface{})
+	s.dirty = true
+}
+
+// Flash 添加一条只会被读取一次的消息
+func (s *Session) Flash(value interface{}) {
+	flashes, _ := s.Values[flashesKey].([]interface{})
+	s.Values[flashesKey] = append(flashes, value)
+	s.dirty = true
+}
+
+// Flashes 取出并清空所有 flash 消息
+func (s *Session) Flashes() []interface{} {
+	flashes, ok := s.Values[flashesKey].([]interface{})
+	if ok {
+		delete(s.Values, flashesKey)
+		s.dirty = true
+	}
+	return flashes
+}
+
+// Invalidate 在保存时删除会话
+func (s *Session) Invalidate() {
+	s.Values = make(map[string]interface{})
+	s.Options.MaxAge = -1
+	s.dirty = true
+}
+
+// Save 立即保存会话，一般不需要手动调用
+func (s *Session) Save(c *Context) error {
+	s.dirty = false
+	return s.store.Save(c, s)
+}
+
+// Store 会话存储
+type Store interface {
+	// Load 返回名为 name 的会话，不存在或无效时返回新会话和对应的错误
+	Load(c *Context, name string) (*Session, error)
+	// Save 保存会话并写入 cookie
+	Save(c *Context, s *Session) error
+}
+
+// Sessions 中间件加载会话，并在响应头写出前保存修改
+func Sessions(name string, store Store) HandlerFunc {
+	return func(c *Context) {
+		s, err := store.Load(c, name)
+		if err != nil && err != ErrSessionNotFound && err != http.ErrNoCookie {
+			log.Printf("[Sessions] load %s: %v", name, err)
+		}
+		if s == nil {
+			s = NewSession(store, name)
+		}
+		c.Set(sessionContextKey, s)
+		save := func() {
+			if !s.dirty {
+				return
+			}
+			if err := s.Save(c); err != nil {
+				log.Printf("[Sessions] save %s: %v", name, err)
+			}
+		}
+		c.BeforeWrite(save)
+		c.Next()
+		if !c.Written() {
+			save()
+		}
+	}
+}
+
+// Session 返回 Sessions 中间件加载的会话，未安装中间件时返回 nil
+func (c *Context) Session() *Session {
+	if s, ok := c.Get(sessionContextKey); ok {
+		return s.(*Session)
+	}
+	return nil
+}
+
+func encodeValues(values map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeValues(data []byte, values *map[string]interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(values)
+}
+
+// loadByID 从 cookie 中解出会话 ID，再通过 get 读取会话数据，供只在 cookie 里保存 ID 的 Store 使用
+func loadByID(c *Context, codec CookieCodec, s *Session, get func(id string) ([]byte, error)) error {
+	value, err := c.Cookie(s.name)
+	if err != nil {
+		return err
+	}
+	id, err := codec.Decode(s.name, value)
+	if err != nil {
+		return err
+	}
+	data, err := get(string(id))
+	if err != nil {
+		return err
+	}
+	if err := decodeValues(data, &s.Values); err != nil {
+		return err
+	}
+	s.ID = string(id)
+	s.IsNew = false
+	return nil
+}
+
+// encodeByID 为新会话分配 ID，返回序列化的会话数据和签名后的 cookie 值
+func encodeByID(codec CookieCodec, s *Session) (data []byte, value string, err error) {
+	if s.ID == "" {
+		s.ID = newSessionID()
+	}
+	if data, err = encodeValues(s.Values); err != nil {
+		return nil, "", err
+	}
+	value, err = codec.Encode(s.name, []byte(s.ID))
+	return data, value, err
+}
+
+func newSessionID() string {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+/********************************CookieStore*************************************/
+
+// 浏览器对单个 cookie 的限制
+const maxCookieSize = 4096
+
+// CookieStore 把会话数据编码后整个保存在 cookie 中，
+// Codec 为 NewSignedCodec 时数据可读但不可篡改，为 NewEncryptedCodec 时数据同时被加密。
+type CookieStore struct {
+	Codec   CookieCodec
+	Options SessionOptions
+}
+
+func NewCookieStore(codec CookieCodec) *CookieStore {
+	return &CookieStore{Codec: codec, Options: DefaultSessionOptions}
+}
+
+func (st *CookieStore) Load(c *Context, name string) (*Session, error) {
+	s := NewSession(st, name)
+	s.Options = st.Options
+	value, err := c.Cookie(name)
+	if err != nil {
+		return s, err
+	}
+	data, err := st.Codec.Decode(name, value)
+	if err != nil {
+		return s, err
+	}
+	if err := decodeValues(data, &s.Values); err != nil {
+		return s, err
+	}
+	s.IsNew = false
+	return s, nil
+}
+
+func (st *CookieStore) Save(c *Context, s *Session) error {
+	if s.Options.MaxAge < 0 {
+		c.SetCookie(s.Options.cookie(s.name, ""))
+		return nil
+	}
+	data, err := encodeValues(s.Values)
+	if err != nil {
+		return err
+	}
+	value, err := st.Codec.Encode(s.name, data)
+	if err != nil {
+		return err
+	}
+	if len(value) > maxCookieSize {
+		return fmt.Errorf("gee: session %s too large for a cookie: %d bytes", s.name, len(value))
+	}
+	c.SetCookie(s.Options.cookie(s.name, value))
+	return nil
+}
+
+var _ Store = (*CookieStore)(nil)
+
+/********************************MemoryStore*************************************/
+
+type memorySession struct {
+	data    []byte
+	expires time.Time // 零值表示不过期
+}
+
+func (m memorySession) expired(now time.Time) bool {
+	return !m.expires.IsZero() && now.After(m.expires)
+}
+
+// MemoryStore 在内存中保存会话数据，cookie 中只保存签名后的会话 ID。
+// 过期的会话在访问时惰性删除，也可以定期调用 GC 回收。
+type MemoryStore struct {
+	Codec    CookieCodec
+	Options  SessionOptions
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+func NewMemoryStore(codec CookieCodec) *MemoryStore {
+	return &MemoryStore{
+		Codec:    codec,
+		Options:  DefaultSessionOptions,
+		sessions: make(map[string]memorySession),
+	}
+}
+
+func (st *MemoryStore) Load(c *Context, name string) (*Session, error) {
+	s := NewSession(st, name)
+	s.Options = st.Options
+	return s, loadByID(c, st.Codec, s, st.Get)
+}
+
+func (st *MemoryStore) Save(c *Context, s *Session) error {
+	if s.Options.MaxAge < 0 {
+		st.mu.Lock()
+		delete(st.sessions, s.ID)
+		st.mu.Unlock()
+		c.SetCookie(s.Options.cookie(s.name, ""))
+		return nil
+	}
+	data, value, err := encodeByID(st.Codec, s)
+	if err != nil {
+		return err
+	}
+	entry := memorySession{data: data}
+	if s.Options.MaxAge > 0 {
+		entry.expires = time.Now().Add(time.Duration(s.Options.MaxAge) * time.Second)
+	}
+	st.mu.Lock()
+	st.sessions[s.ID] = entry
+	st.mu.Unlock()
+	c.SetCookie(s.Options.cookie(s.name, value))
+	return nil
+}
+
+// Get 返回会话 ID 对应的序列化数据
+func (st *MemoryStore) Get(id string) ([]byte, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	entry, ok := st.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if entry.expired(time.Now()) {
+		delete(st.sessions, id)
+		return nil, ErrSessionNotFound
+	}
+	return entry.data, nil
+}
+
+// GC 删除所有过期的会话
+func (st *MemoryStore) GC() {
+	now := time.Now()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for id, entry := range st.sessions {
+		if entry.expired(now) {
+			delete(st.sessions, id)
+		}
+	}
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+/********************************GeeCacheSessionStore*************************************/
+
+/*
+	GeeCacheSessionStore 把会话数据保存在 geecache.Group 中，cookie 中只保存签名后的会话 ID：
+	1、Save 通过 Group.Set 写入会话 ID 所在的节点，过期时间等于 MaxAge，MaxAge 为 0 时不过期。
+	2、Group.Set 会通知其他节点删除 hotCache 中的旧值，会话更新之后在所有节点上读到的都是新值。
+	3、Invalidate 之后通过 Group.Remove 在所有节点上删除会话。
+	Group 的 Getter 不会回源，不存在的会话返回 geecache.NotFoundError。
+*/
+type GeeCacheSessionStore struct {
+	Codec   CookieCodec
+	Options SessionOptions
+	group   *geecache.Group
+}
+
+// NewGeeCacheSessionStore 创建名为 name 的 geecache.Group 保存会话数据
+func NewGeeCacheSessionStore(name string, cacheBytes int64, codec CookieCodec) *GeeCacheSessionStore {
+	getter := geecache.GetterFunc(func(id string) ([]byte, error) {
+		return nil, &geecache.NotFoundError{Key: id}
+	})
+	return &GeeCacheSessionStore{
+		Codec:   codec,
+		Options: DefaultSessionOptions,
+		group:   geecache.NewGroup(name, cacheBytes, getter),
+	}
+}
+
+// Group 返回底层的 geecache.Group，可以用来注册 peers
+func (st *GeeCacheSessionStore) Group() *geecache.Group {
+	return st.group
+}
+
+func (st *GeeCacheSessionStore) Load(c *Context, name string) (*Session, error) {
+	s := NewSession(st, name)
+	s.Options = st.Options
+	return s, loadByID(c, st.Codec, s, st.get)
+}
+
+func (st *GeeCacheSessionStore) get(id string) ([]byte, error) {
+	view, err := st.group.Get(id)
+	if geecache.IsNotFound(err) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return view.ByteSlice(), nil
+}
+
+func (st *GeeCacheSessionStore) Save(c *Context, s *Session) error {
+	if s.Options.MaxAge < 0 {
+		c.SetCookie(s.Options.cookie(s.name, ""))
+		if s.ID == "" {
+			return nil
+		}
+		return st.group.Remove(s.ID)
+	}
+	data, value, err := encodeByID(st.Codec, s)
+	if err != nil {
+		return err
+	}
+	ttl := time.Duration(-1) // Group.Set 的 ttl 小于 0 表示不过期
+	if s.Options.MaxAge > 0 {
+		ttl = time.Duration(s.Options.MaxAge) * time.Second
+	}
+	if err := st.group.Set(s.ID, data, ttl); err != nil {
+		return err
+	}
+	c.SetCookie(s.Options.cookie(s.name, value))
+	return nil
+}
+
+var _ Store = (*GeeCacheSessionStore)(nil)
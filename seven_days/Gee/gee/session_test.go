@@ -0,0 +1,187 @@
+package gee
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// oldPayload 构造一个 2 小时前的时间戳，用来测试过期
+func oldPayload(value []byte) []byte {
+	b := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(time.Now().Add(-2*time.Hour).Unix()))
+	copy(b[8:], value)
+	return b
+}
+
+func TestSignedCodec(t *testing.T) {
+	codec := NewSignedCodec([]byte("hash-key"), time.Hour)
+	value, err := codec.Encode("sess", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := codec.Decode("sess", value); err != nil || string(data) != "hello" {
+		t.Fatalf("round trip = %q, %v", data, err)
+	}
+
+	tampered := []byte(value)
+	tampered[0] ^= 1
+	if _, err := codec.Decode("sess", string(tampered)); err != ErrCookieInvalid {
+		t.Fatalf("tampered value: %v, want ErrCookieInvalid", err)
+	}
+	if _, err := codec.Decode("other", value); err != ErrCookieInvalid {
+		t.Fatalf("value moved to another cookie: %v, want ErrCookieInvalid", err)
+	}
+	if _, err := NewSignedCodec([]byte("other-key"), time.Hour).Decode("sess", value); err != ErrCookieInvalid {
+		t.Fatalf("wrong key: %v, want ErrCookieInvalid", err)
+	}
+
+	s := codec.(*signedCodec)
+	payload := oldPayload([]byte("hello"))
+	expired := cookieEncoding.EncodeToString(payload) + "." + cookieEncoding.EncodeToString(s.mac("sess", payload))
+	if _, err := codec.Decode("sess", expired); err != ErrCookieExpired {
+		t.Fatalf("expired value: %v, want ErrCookieExpired", err)
+	}
+}
+
+func TestEncryptedCodec(t *testing.T) {
+	if _, err := NewEncryptedCodec([]byte("short"), 0); err == nil {
+		t.Fatal("invalid AES key length should fail")
+	}
+	codec, err := NewEncryptedCodec([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := codec.Encode("sess", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := codec.Decode("sess", value); err != nil || string(data) != "secret" {
+		t.Fatalf("round trip = %q, %v", data, err)
+	}
+
+	raw, _ := cookieEncoding.DecodeString(value)
+	raw[len(raw)-1] ^= 1
+	if _, err := codec.Decode("sess", cookieEncoding.EncodeToString(raw)); err != ErrCookieInvalid {
+		t.Fatalf("tampered value: %v, want ErrCookieInvalid", err)
+	}
+	if _, err := codec.Decode("other", value); err != ErrCookieInvalid {
+		t.Fatalf("value moved to another cookie: %v, want ErrCookieInvalid", err)
+	}
+
+	e := codec.(*encryptedCodec)
+	nonce := make([]byte, e.aead.NonceSize())
+	expired := cookieEncoding.EncodeToString(e.aead.Seal(nonce, nonce, oldPayload([]byte("secret")), []byte("sess")))
+	if _, err := codec.Decode("sess", expired); err != ErrCookieExpired {
+		t.Fatalf("expired value: %v, want ErrCookieExpired", err)
+	}
+}
+
+// newSessionEngine /set 写入 user，/get 读出 user，/logout 删除会话
+func newSessionEngine(store Store) *Engine {
+	r := New()
+	r.Use(Sessions("sess", store))
+	r.GET("/set", func(c *Context) {
+		c.Session().Set("user", c.Query("user"))
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/get", func(c *Context) {
+		user, _ := c.Session().Get("user").(string)
+		c.String(http.StatusOK, user)
+	})
+	r.GET("/logout", func(c *Context) {
+		c.Session().Invalidate()
+		c.String(http.StatusOK, "bye")
+	})
+	return r
+}
+
+func sessionCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "sess" {
+			return cookie
+		}
+	}
+	t.Fatalf("response has no session cookie: %v", w.Header())
+	return nil
+}
+
+func testStore(t *testing.T, store Store) {
+	r := newSessionEngine(store)
+	cookie := sessionCookie(t, serve(r, "GET", "/set?user=geektutu", nil))
+	if w := serve(r, "GET", "/get", withCookie(nil, cookie)); w.Body.String() != "geektutu" {
+		t.Fatalf("session value = %q, want geektutu", w.Body.String())
+	}
+	if w := serve(r, "GET", "/get", nil); w.Body.String() != "" || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("request without cookie should get an empty, unsaved session")
+	}
+
+	tampered := *cookie
+	tampered.Value = "x" + cookie.Value[1:]
+	if w := serve(r, "GET", "/get", withCookie(nil, &tampered)); w.Body.String() != "" {
+		t.Fatalf("tampered cookie should be rejected, got %q", w.Body.String())
+	}
+
+	logout := sessionCookie(t, serve(r, "GET", "/logout", withCookie(nil, cookie)))
+	if logout.MaxAge >= 0 || logout.Value != "" {
+		t.Fatalf("logout should delete the cookie, got %+v", logout)
+	}
+}
+
+func TestCookieStore(t *testing.T) {
+	codec, err := NewEncryptedCodec([]byte("0123456789abcdef"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, NewCookieStore(codec))
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(NewSignedCodec([]byte("hash-key"), 0))
+	testStore(t, store)
+
+	// logout 之后服务端的数据也被删除
+	r := newSessionEngine(store)
+	cookie := sessionCookie(t, serve(r, "GET", "/set?user=a", nil))
+	serve(r, "GET", "/logout", withCookie(nil, cookie))
+	if w := serve(r, "GET", "/get", withCookie(nil, cookie)); w.Body.String() != "" {
+		t.Fatalf("invalidated session should be gone, got %q", w.Body.String())
+	}
+
+	// 过期的会话被 GC 回收
+	store.Options.MaxAge = 1
+	serve(r, "GET", "/set?user=b", nil)
+	store.mu.Lock()
+	for id, entry := range store.sessions {
+		entry.expires = time.Now().Add(-time.Second)
+		store.sessions[id] = entry
+	}
+	store.mu.Unlock()
+	store.GC()
+	if n := len(store.sessions); n != 0 {
+		t.Fatalf("GC left %d expired sessions", n)
+	}
+}
+
+func TestGeeCacheSessionStore(t *testing.T) {
+	store := NewGeeCacheSessionStore(fmt.Sprintf("gee-session-test-%d", time.Now().UnixNano()), 1<<20, NewSignedCodec([]byte("hash-key"), 0))
+	testStore(t, store)
+
+	// 会话更新之后读到新值，logout 之后 Group 中的数据也被删除
+	r := newSessionEngine(store)
+	cookie := sessionCookie(t, serve(r, "GET", "/set?user=a", nil))
+	serve(r, "GET", "/set?user=b", withCookie(nil, cookie))
+	if w := serve(r, "GET", "/get", withCookie(nil, cookie)); w.Body.String() != "b" {
+		t.Fatalf("updated session value = %q, want b", w.Body.String())
+	}
+	serve(r, "GET", "/logout", withCookie(nil, cookie))
+	if w := serve(r, "GET", "/get", withCookie(nil, cookie)); w.Body.String() != "" {
+		t.Fatalf("invalidated session should be gone, got %q", w.Body.String())
+	}
+	if s := store.Group().Stats(); s.MainCache.Items != 0 {
+		t.Fatalf("group still holds %d sessions", s.MainCache.Items)
+	}
+}
@@ -1,3 +1,18 @@
 module Gee
 
-go 1.18
+go 1.20
+
+require (
+	GeeCache v0.0.0-00010101000000-000000000000
+	golang.org/x/net v0.17.0
+)
+
+require (
+	GeeRPC v0.0.0-00010101000000-000000000000 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
+
+replace GeeCache => ../GeeCache
+
+replace GeeRPC => ../GeeRPC
@@ -2,35 +2,213 @@ package geecache
 
 import (
 	"GeeCache/geecache/lru"
+	"GeeCache/geecache/policy"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+
+   1、cache 按 key 的 hash 分成多个 shard，每个 shard 有自己的锁、淘汰策略和 cacheBytes/shards 的容量，不同 shard 之间互不竞争。
+   2、命中时只加读锁调用 Peek，访问记录先放进 shard 的缓冲区，缓冲区积累到 accessBatch 个之后由抢到写锁的 goroutine 批量调用 Get 更新访问顺序。
+      缓冲区满时直接丢弃访问记录，相当于对访问顺序采样，读多的场景下不会因为更新访问顺序而排队。
+   3、未命中时加写锁调用 Get，过期条目在这里被删除，TinyLFU 之类的策略也会记录这次访问。
+   4、nbytes、nitems 记录所有 shard 的总大小和条目数，每次加写锁操作之后更新，bytes() 和 stats() 不需要加锁。
+
+*/
+
+const (
+	defaultShards = 16
+	minShardBytes = 64 << 10 // 自动选择 shard 数时，每个 shard 至少 64KB
+	accessBatch   = 32
+	accessBuffer  = 64
 )
 
 type cache struct {
-	mu         sync.Mutex
-	lru        *lru.Cache
+	once       sync.Once
+	shards     []*cacheShard
+	nshards    int            // 0 表示按 cacheBytes 自动选择
+	newPolicy  policy.Factory // 为 nil 时使用 policy.NewLRU
 	cacheBytes int64
+	nbytes     int64 // 所有 shard 的总大小，原子操作
+	nitems     int64 // 所有 shard 的条目数，原子操作
+	counters   cacheStats
+	onEvicted  func(key string, value ByteView, reason lru.EvictReason)
+	clock      lru.Clock // 为 nil 时使用 lru.SystemClock
+}
+
+type cacheShard struct {
+	mu       sync.RWMutex
+	policy   policy.Policy
+	accesses chan string // 尚未更新到 policy 的命中记录
+}
+
+// shardCount cacheBytes 太小时减少 shard 数，避免每个 shard 只能放下很少的条目
+func (c *cache) shardCount() int {
+	if c.nshards > 0 {
+		return c.nshards
+	}
+	n := defaultShards
+	for n > 1 && c.cacheBytes > 0 && c.cacheBytes/int64(n) < minShardBytes {
+		n /= 2
+	}
+	return n
+}
+
+// lazyInit 延迟创建各个 shard 的淘汰策略
+func (c *cache) lazyInit() {
+	c.once.Do(func() {
+		n := c.shardCount()
+		opts := policy.Options{MaxBytes: c.cacheBytes / int64(n), Clock: c.clock}
+		opts.OnEvicted = func(key string, value lru.Value, reason lru.EvictReason) {
+			if reason != lru.EvictRemoved {
+				c.counters.evictions.Add(1)
+			}
+			if c.onEvicted != nil {
+				c.onEvicted(key, value.(ByteView), reason)
+			}
+		}
+		newPolicy := c.newPolicy
+		if newPolicy == nil {
+			newPolicy = policy.NewLRU
+		}
+		c.shards = make([]*cacheShard, n)
+		for i := range c.shards {
+			c.shards[i] = &cacheShard{policy: newPolicy(opts), accesses: make(chan string, accessBuffer)}
+		}
+	})
+}
+
+// shard 用 FNV-1a 选择 key 所在的 shard
+func (c *cache) shard(key string) *cacheShard {
+	c.lazyInit()
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
+
+// write 加写锁执行 fn，先把缓冲的访问记录更新到 policy，最后更新 nbytes
+func (c *cache) write(s *cacheShard, fn func(p policy.Policy)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.writeLocked(s, fn)
 }
 
-func (c *cache) add(key string, value ByteView) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		c.lru = lru.New(c.cacheBytes, nil)
-		c.lru.Add(key, value)
+// writeLocked 同 write，调用时需要持有 s.mu 的写锁
+func (c *cache) writeLocked(s *cacheShard, fn func(p policy.Policy)) {
+	before, items := s.policy.Bytes(), s.policy.Len()
+	for len(s.accesses) > 0 {
+		key := <-s.accesses
+		if _, ok := s.policy.Peek(key); ok { // 只回放仍然有效的条目，过期条目留给 Get 和 removeExpired 处理
+			s.policy.Get(key)
+		}
 	}
-	c.lru.Add(key, value)
+	if fn != nil {
+		fn(s.policy)
+	}
+	atomic.AddInt64(&c.nbytes, s.policy.Bytes()-before)
+	atomic.AddInt64(&c.nitems, int64(s.policy.Len()-items))
+}
+
+// add 添加缓存，ttl <= 0 表示不过期
+func (c *cache) add(key string, value ByteView, ttl time.Duration) {
+	c.write(c.shard(key), func(p policy.Policy) {
+		p.AddWithTTL(key, value, ttl)
+	})
 }
 
 func (c *cache) get(key string) (value ByteView, ok bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.counters.gets.Add(1)
+	s := c.shard(key)
+	s.mu.RLock()
+	v, ok := s.policy.Peek(key)
+	s.mu.RUnlock()
+	if !ok {
+		c.write(s, func(p policy.Policy) {
+			v, ok = p.Get(key)
+		})
+		if !ok {
+			return
+		}
+		c.counters.hits.Add(1)
+		return v.(ByteView), true
+	}
+	c.counters.hits.Add(1)
 
-	if c.lru == nil {
-		return
+	select {
+	case s.accesses <- key:
+	default: // 缓冲区满了，丢弃这次访问记录
 	}
-	if v, ok := c.lru.Get(key); ok {
-		return v.(ByteView), ok
+	if len(s.accesses) >= accessBatch && s.mu.TryLock() {
+		c.writeLocked(s, nil)
+		s.mu.Unlock()
 	}
+	return v.(ByteView), true
+}
 
-	return
+// expires 返回条目的过期时间，零值表示不过期
+func (c *cache) expires(key string) (time.Time, bool) {
+	s := c.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy.Expires(key)
+}
+
+func (c *cache) remove(key string) {
+	c.write(c.shard(key), func(p policy.Policy) {
+		p.Remove(key)
+	})
+}
+
+// removeOldest 在占用最多的 shard 中按淘汰策略淘汰一个条目
+func (c *cache) removeOldest() {
+	c.lazyInit()
+	var victim *cacheShard
+	var most int64
+	for _, s := range c.shards {
+		s.mu.RLock()
+		n := s.policy.Bytes()
+		s.mu.RUnlock()
+		if n > most {
+			victim, most = s, n
+		}
+	}
+	if victim != nil {
+		c.write(victim, func(p policy.Policy) {
+			p.RemoveOldest()
+		})
+	}
+}
+
+// removeExpired 回收过期条目，返回回收的个数
+func (c *cache) removeExpired() int {
+	c.lazyInit()
+	n := 0
+	for _, s := range c.shards {
+		c.write(s, func(p policy.Policy) {
+			n += p.RemoveExpired()
+		})
+	}
+	return n
+}
+
+func (c *cache) bytes() int64 {
+	return atomic.LoadInt64(&c.nbytes)
+}
+
+func (c *cache) stats() CacheStats {
+	return CacheStats{
+		Bytes:     c.bytes(),
+		Items:     atomic.LoadInt64(&c.nitems),
+		Gets:      c.counters.gets.Get(),
+		Hits:      c.counters.hits.Get(),
+		Evictions: c.counters.evictions.Get(),
+	}
 }
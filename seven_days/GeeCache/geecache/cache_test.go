@@ -0,0 +1,99 @@
+package geecache
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// 测试 shard 数的选择和容量：每个 shard 的容量是 cacheBytes/shards，nbytes 是所有 shard 的总和
+func TestCacheShards(t *testing.T) {
+	if n := (&cache{cacheBytes: 2 << 10}).shardCount(); n != 1 {
+		t.Fatalf("small cache should use 1 shard, got %d", n)
+	}
+	if n := (&cache{cacheBytes: 64 << 20}).shardCount(); n != defaultShards {
+		t.Fatalf("large cache should use %d shards, got %d", defaultShards, n)
+	}
+
+	c := &cache{cacheBytes: 800, nshards: 4}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("key%d", j)
+				if _, ok := c.get(key); !ok {
+					c.add(key, ByteView{b: []byte("vvvv")}, 0)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var total int64
+	for _, s := range c.shards {
+		if n := s.policy.Bytes(); n > 200 {
+			t.Fatalf("shard uses %d bytes, want <= 200", n)
+		} else {
+			total += n
+		}
+	}
+	if c.bytes() != total {
+		t.Fatalf("bytes() = %d, shards total %d", c.bytes(), total)
+	}
+}
+
+/*
+
+   并行读的 benchmark，比较不同 shard 数下的吞吐：
+   go test -bench CacheGetParallel -run none -cpu 1,4,8 ./geecache
+
+*/
+func BenchmarkCacheGetParallel(b *testing.B) {
+	const keys = 10000
+	for _, shards := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := &cache{nshards: shards}
+			names := make([]string, keys)
+			for i := range names {
+				names[i] = fmt.Sprintf("key%d", i)
+				c.add(names[i], ByteView{b: []byte("value")}, 0)
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					c.get(names[r.Intn(keys)])
+				}
+			})
+		})
+	}
+}
+
+// 读写混合（10% 写）
+func BenchmarkCacheMixedParallel(b *testing.B) {
+	const keys = 10000
+	for _, shards := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := &cache{nshards: shards, cacheBytes: keys * 8}
+			names := make([]string, keys)
+			for i := range names {
+				names[i] = fmt.Sprintf("key%d", i)
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					key := names[r.Intn(keys)]
+					if r.Intn(10) == 0 {
+						c.add(key, ByteView{b: []byte("value")}, 0)
+					} else {
+						c.get(key)
+					}
+				}
+			})
+		})
+	}
+}
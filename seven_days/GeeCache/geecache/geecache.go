@@ -1,10 +1,18 @@
 package geecache
 
 import (
+	pb "GeeCache/geecache/geecachepb"
+	"GeeCache/geecache/lru"
+	"GeeCache/geecache/policy"
 	"GeeCache/geecache/singleflight"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 /*
@@ -27,6 +35,62 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+/*
+
+   Getter 还可以实现 TTLGetter，为每个 key 返回单独的过期时间：
+   ttl == 0 使用 Group 的默认 TTL，ttl < 0 表示不过期。
+
+*/
+
+// TTLGetter 为键加载数据，同时返回过期时间
+type TTLGetter interface {
+	Getter
+	GetWithTTL(key string) ([]byte, time.Duration, error)
+}
+
+// TTLGetterFunc 通过函数实现 TTLGetter
+type TTLGetterFunc func(key string) ([]byte, time.Duration, error)
+
+func (f TTLGetterFunc) Get(key string) ([]byte, error) {
+	b, _, err := f(key)
+	return b, err
+}
+
+func (f TTLGetterFunc) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	return f(key)
+}
+
+/*
+
+   Getter 返回 NotFoundError（或者用 %w 包装了 ErrNotFound 的错误）表示 key 不存在，
+   Group 会把结果作为否定条目缓存 negativeTTL，期间的 Get 直接返回 NotFoundError，不再访问数据源。
+   其他错误不会被缓存。
+   否定条目单独计算容量，cacheBytes 为 0（不限制）时也使用 defaultNegativeBytes，
+   没有启动 StartJanitor 时过期条目只在访问时删除，容量满后按淘汰策略移除。
+
+*/
+
+// ErrNotFound key 不存在，用 errors.Is(err, ErrNotFound) 判断
+var ErrNotFound = errors.New("geecache: key not found")
+
+// NotFoundError key 不存在
+type NotFoundError struct {
+	Key string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("geecache: key %q not found", e.Key)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// IsNotFound 判断 err 是否表示 key 不存在
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
 /*
 
    一个 Group 可以认为是一个缓存的命名空间，每个 Group 拥有一个唯一的名称 name。比如可以创建三个 Group，缓存学生的成绩命名为 scores，缓存学生信息的命名为 info，缓存学生课程的命名为 courses。
@@ -37,13 +101,37 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 
 */
 
+/*
+
+   mainCache 保存本节点负责的 key，hotCache 保存从其他节点取回的热点 key，避免每次都跨网络访问。
+   从 peer 取回的值以 1/hotChance 的概率放入 hotCache，越热的 key 越容易被缓存下来。
+   两个缓存共享 cacheBytes，超出时如果 hotCache 超过 cacheBytes*hotRatio 就淘汰 hotCache，否则淘汰 mainCache。
+
+*/
+
+const (
+	defaultHotRatio      = 0.125
+	defaultHotChance     = 10
+	defaultNegativeTTL   = 10 * time.Second
+	defaultNegativeRatio = 1.0 / 16 // negCache 默认的容量是 cacheBytes 的 1/16
+	defaultNegativeBytes = 1 << 20  // cacheBytes 为 0 或按比例算出的容量为 0 时 negCache 的容量
+)
+
 // Group 是一个缓存命名空间，并将加载的相关数据分散开来
 type Group struct {
-	name      string
-	getter    Getter // 回调函数
-	mainCache cache
-	peers     PeerPicker
-	loader    *singleflight.Group // 确保相同的 key 只调用一次
+	name       string
+	getter     Getter // 回调函数
+	mainCache  cache
+	hotCache   cache
+	negCache   cache         // 否定条目，值为空，单独计算容量
+	negTTL     time.Duration // 否定条目的过期时间，0 表示不缓存
+	cacheBytes int64         // mainCache 和 hotCache 共用的容量，0 表示不限制
+	hotRatio   float64       // hotCache 最多占 cacheBytes 的比例
+	hotChance  int           // 从 peer 取回的值以 1/hotChance 的概率放入 hotCache
+	peers      PeerPicker
+	loader     *singleflight.Group // 确保相同的 key 只调用一次
+	ttl        time.Duration       // 默认过期时间，0 表示不过期
+	stats      groupStats
 }
 
 var (
@@ -51,18 +139,73 @@ var (
 	groups = make(map[string]*Group)
 )
 
-// NewGroup 创建 Group 的新实例
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+// Option 创建 Group 时的可选配置
+type Option func(g *Group)
+
+// WithPolicy 设置淘汰策略，例如 policy.NewLFU、policy.NewARC、policy.NewTinyLFU，默认 policy.NewLRU
+func WithPolicy(newPolicy policy.Factory) Option {
+	return func(g *Group) {
+		g.mainCache.newPolicy = newPolicy
+		g.hotCache.newPolicy = newPolicy
+	}
+}
+
+// WithShards 把本地缓存分成 n 个 shard，默认按 cacheBytes 自动选择，最多 16 个
+func WithShards(n int) Option {
+	return func(g *Group) {
+		g.mainCache.nshards = n
+		g.hotCache.nshards = n
+	}
+}
+
+// WithNegativeCache 设置否定条目的过期时间和容量，ttl 为 0 时不缓存 ErrNotFound，
+// 默认缓存 10s，容量为 cacheBytes 的 1/16，cacheBytes <= 0 时使用 defaultNegativeBytes
+func WithNegativeCache(ttl time.Duration, cacheBytes int64) Option {
+	return func(g *Group) {
+		g.negTTL = ttl
+		g.negCache.cacheBytes = cacheBytes
+	}
+}
+
+// WithTTL 同 SetTTL
+func WithTTL(ttl time.Duration) Option {
+	return func(g *Group) { g.SetTTL(ttl) }
+}
+
+// WithHotRatio 同 SetHotRatio
+func WithHotRatio(ratio float64) Option {
+	return func(g *Group) { g.SetHotRatio(ratio) }
+}
+
+/*
+
+   NewGroup 创建 Group 的新实例。
+   mainCache 的策略按 cacheBytes 限制容量，这样 W-TinyLFU 之类的策略可以自己决定是否接纳新条目；
+   hotCache 不单独限制，两个缓存的总大小由 populateCache 控制。
+
+*/
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...Option) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
 	mu.Lock()
 	defer mu.Unlock()
 	g := &Group{
-		name:      name,
-		getter:    getter,
-		mainCache: cache{cacheBytes: cacheBytes},
-		loader:    &singleflight.Group{},
+		name:       name,
+		getter:     getter,
+		cacheBytes: cacheBytes,
+		mainCache:  cache{cacheBytes: cacheBytes},
+		negCache:   cache{cacheBytes: int64(float64(cacheBytes) * defaultNegativeRatio)},
+		negTTL:     defaultNegativeTTL,
+		hotRatio:   defaultHotRatio,
+		hotChance:  defaultHotChance,
+		loader:     &singleflight.Group{},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.negCache.cacheBytes <= 0 {
+		g.negCache.cacheBytes = defaultNegativeBytes
 	}
 	groups[name] = g
 	return g
@@ -93,16 +236,140 @@ func GetGroup(name string) *Group {
 
 */
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext 同 Get，ctx 结束时不再等待加载，加载本身不会被取消，其他等待同一个 key 的调用者仍然可以拿到结果
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is required")
 	}
 
-	if v, ok := g.mainCache.get(key); ok {
-		log.Println("[GeeCache] hit")
+	g.stats.gets.Add(1)
+	if v, ok := g.lookupCache(key); ok {
+		g.stats.hits.Add(1)
 		return v, nil
 	}
+	if _, ok := g.negCache.get(key); ok {
+		g.stats.negativeHits.Add(1)
+		return ByteView{}, &NotFoundError{Key: key}
+	}
+
+	g.stats.misses.Add(1)
+	return g.load(ctx, key)
+}
+
+// SetTTL 设置默认过期时间，需要在使用 Group 之前调用
+func (g *Group) SetTTL(ttl time.Duration) {
+	g.ttl = ttl
+}
+
+// SetHotRatio 设置 hotCache 最多占用 cacheBytes 的比例，默认 1/8，0 表示不使用 hotCache
+func (g *Group) SetHotRatio(ratio float64) {
+	g.hotRatio = ratio
+}
+
+// SetOnEvicted 设置条目被移除时的回调，reason 区分过期和容量淘汰，需要在使用 Group 之前调用
+func (g *Group) SetOnEvicted(fn func(key string, value ByteView, reason lru.EvictReason)) {
+	g.mainCache.onEvicted = fn
+	g.hotCache.onEvicted = fn
+}
+
+// StartJanitor 每隔 interval 回收一次过期条目占用的内存，返回停止函数
+func (g *Group) StartJanitor(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if n := g.mainCache.removeExpired() + g.hotCache.removeExpired() + g.negCache.removeExpired(); n > 0 {
+					log.Printf("[GeeCache] %s: reclaimed %d expired entries", g.name, n)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+/*
+
+   数据源变化时通过 Set/Remove 主动更新缓存：
+   1、Set 把新值写入 key 所在节点（PickPeer 选中的节点或自己）的 mainCache。
+   2、其他节点的 hotCache 中可能还有旧值，所以 Set 和 Remove 都会向所有节点广播删除。
+   正在进行中的 load 仍可能在删除之后写入旧值，需要强一致时应配合较短的 TTL 使用。
+
+*/
+
+// Set 更新 key 的值，ttl 为 0 时使用默认 TTL，小于 0 表示不过期
+func (g *Group) Set(key string, value []byte, ttl time.Duration) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	view := ByteView{b: cloneBytes(value)}
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			req := &pb.SetRequest{Group: g.name, Key: key, Value: view.b, TtlMs: ttlToMs(ttl)}
+			if err := peer.Set(req, &pb.Response{}); err != nil {
+				return err
+			}
+			g.hotCache.remove(key)
+			g.negCache.remove(key)
+			return g.broadcastRemove(key, peer)
+		}
+	}
+	g.setLocally(key, view, ttl)
+	return g.broadcastRemove(key, nil)
+}
+
+// Remove 在所有节点上删除 key
+func (g *Group) Remove(key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	g.removeLocally(key)
+	return g.broadcastRemove(key, nil)
+}
 
-	return g.load(key)
+// broadcastRemove 通知除 skip 以外的所有节点删除 key，返回第一个错误
+func (g *Group) broadcastRemove(key string, skip PeerGetter) error {
+	if g.peers == nil {
+		return nil
+	}
+	var first error
+	failed := 0
+	for _, peer := range g.peers.GetAll() {
+		if peer == skip {
+			continue
+		}
+		if err := peer.Remove(&pb.Request{Group: g.name, Key: key}, &pb.Response{}); err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	if first != nil {
+		return fmt.Errorf("remove %s from %d peers: %v", key, failed, first)
+	}
+	return nil
+}
+
+// setLocally 由 key 所在的节点调用，写入 mainCache
+func (g *Group) setLocally(key string, value ByteView, ttl time.Duration) {
+	g.hotCache.remove(key)
+	g.negCache.remove(key)
+	g.populateCache(key, value, ttl, &g.mainCache)
+}
+
+func (g *Group) removeLocally(key string) {
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+	g.negCache.remove(key)
 }
 
 // RegisterPeers 注册一个PeerPicker (type HTTPPool)
@@ -113,46 +380,159 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 	g.peers = peers
 }
 
-func (g *Group) load(key string) (value ByteView, err error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
 	// 在高并发时，确保每个键只获得一次（远程、本地）
-	viewi, err := g.loader.Do(key, func() (interface{}, error) {
+	var executed int32 // 只有真正执行加载的调用者的 fn 会被执行，其他调用者共用它的结果
+	viewi, err, _ := g.loader.Do(ctx, key, func() (interface{}, error) {
+		atomic.StoreInt32(&executed, 1)
 		if g.peers != nil {
 			if peer, ok := g.peers.PickPeer(key); ok {
-				if value, err = g.getFromPeer(peer, key); err == nil {
+				value, err := g.getFromPeer(peer, key)
+				if err == nil {
+					g.stats.peerLoads.Add(1)
 					return value, nil
 				}
+				if IsNotFound(err) { // key 在 owner 上也不存在，不再回源
+					g.stats.peerLoads.Add(1)
+					g.populateNegative(key)
+					return nil, &NotFoundError{Key: key}
+				}
+				g.stats.peerErrors.Add(1)
 				log.Println("[GeeCache] Failed to get from peer", err)
 			}
 		}
-		return g.getLocally(key)
+		value, err := g.getLocally(key)
+		if IsNotFound(err) { // 数据源确认 key 不存在，算作一次成功的加载
+			g.stats.localLoads.Add(1)
+			g.populateNegative(key)
+			return nil, err
+		}
+		if err != nil {
+			g.stats.localErrors.Add(1)
+			return nil, err
+		}
+		g.stats.localLoads.Add(1)
+		return value, nil
 	})
+	if atomic.LoadInt32(&executed) == 0 && ctx.Err() == nil {
+		g.stats.loadsDeduped.Add(1)
+	}
 	if err == nil {
 		return viewi.(ByteView), nil
 	}
 	return
 }
 
-// 从远端机器获取 key
+// 从远端机器获取 key，按概率放入 hotCache，过期时间与 peer 上的剩余 TTL 一致
 func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
-	bytes, err := peer.Get(g.name, key) // 发送 http 请求获取缓存
-	if err != nil {
+	req := &pb.Request{Group: g.name, Key: key}
+	res := &pb.Response{}
+	if err := peer.Get(req, res); err != nil { // 发送 http 请求获取缓存
 		return ByteView{}, err
 	}
-	return ByteView{b: bytes}, nil
+	value := ByteView{b: res.Value}
+	if g.hotRatio > 0 && rand.Intn(g.hotChance) == 0 {
+		g.populateCache(key, value, msToTTL(res.TtlMs), &g.hotCache)
+	}
+	return value, nil
 }
 
-// 从本地数据库获取 key
+// 从本地数据库获取 key，Getter 实现了 TTLGetter 时使用它返回的过期时间
 func (g *Group) getLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.Get(key)
+	var bytes []byte
+	var ttl time.Duration
+	var err error
+	if tg, ok := g.getter.(TTLGetter); ok {
+		bytes, ttl, err = tg.GetWithTTL(key)
+	} else {
+		bytes, err = g.getter.Get(key)
+	}
 	if err != nil {
 		return ByteView{}, err
 
 	}
 	value := ByteView{b: cloneBytes(bytes)}
-	g.populateCache(key, value)
+	g.populateCache(key, value, ttl, &g.mainCache)
 	return value, nil
 }
 
-func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.add(key, value)
+// populateNegative 缓存 key 不存在的结果，值为空，只占用 key 的大小
+func (g *Group) populateNegative(key string) {
+	if g.negTTL > 0 {
+		g.negCache.add(key, ByteView{}, g.negTTL)
+	}
+}
+
+// ttlOf 返回 key 在本地缓存中剩余的过期时间，不在缓存中时返回 0，不过期时返回 -1
+func (g *Group) ttlOf(key string) time.Duration {
+	expires, ok := g.mainCache.expires(key)
+	if !ok {
+		expires, ok = g.hotCache.expires(key)
+	}
+	if !ok {
+		return 0
+	}
+	if expires.IsZero() {
+		return -1
+	}
+	if ttl := time.Until(expires); ttl > 0 {
+		return ttl
+	}
+	return time.Millisecond
+}
+
+/*
+   节点之间用 ttl_ms 传递过期时间，0 表示使用接收方的默认值，所以：
+   1、不过期统一编码为 noExpiryMs（-1），任何负数都按不过期处理。
+   2、不足 1ms 的正数向上取整为 1ms，不会被截断为 0 而变成默认值。
+*/
+
+const noExpiryMs = -1
+
+// ttlToMs 把 ttl 编码为 ttl_ms
+func ttlToMs(ttl time.Duration) int64 {
+	switch {
+	case ttl < 0:
+		return noExpiryMs
+	case ttl > 0 && ttl < time.Millisecond:
+		return 1
+	}
+	return ttl.Milliseconds()
+}
+
+// msToTTL 把 ttl_ms 解码为 ttl，负数统一为 -1（不过期）
+func msToTTL(ms int64) time.Duration {
+	if ms < 0 {
+		return -1
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func (g *Group) lookupCache(key string) (ByteView, bool) {
+	if v, ok := g.mainCache.get(key); ok {
+		return v, true
+	}
+	return g.hotCache.get(key)
+}
+
+// populateCache 把值放入 c，然后按 hotRatio 在两个缓存之间淘汰，直到总大小不超过 cacheBytes
+func (g *Group) populateCache(key string, value ByteView, ttl time.Duration, c *cache) {
+	if ttl == 0 {
+		ttl = g.ttl
+	}
+	c.add(key, value, ttl)
+	if g.cacheBytes <= 0 {
+		return
+	}
+	for {
+		mainBytes, hotBytes := g.mainCache.bytes(), g.hotCache.bytes()
+		if mainBytes+hotBytes <= g.cacheBytes {
+			return
+		}
+		victim := &g.mainCache
+		if hotBytes > int64(float64(g.cacheBytes)*g.hotRatio) || mainBytes == 0 {
+			victim = &g.hotCache
+		}
+		victim.removeOldest()
+	}
 }
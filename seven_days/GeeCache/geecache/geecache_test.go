@@ -1,10 +1,15 @@
 package geecache
 
 import (
+	pb "GeeCache/geecache/geecachepb"
+	"GeeCache/geecache/lru"
+	"GeeCache/geecache/policy"
+	"context"
 	"fmt"
 	"log"
 	"reflect"
 	"testing"
+	"time"
 )
 
 /*
@@ -60,3 +65,303 @@ func TestGet(t *testing.T) {
 		t.Fatalf("the value of unknow should be empty, but %s got", view)
 	}
 }
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time { return f.now }
+
+func (f *fakeClock) Advance(d time.Duration) { f.now = f.now.Add(d) }
+
+func TestTTL(t *testing.T) {
+	loads := make(map[string]int)
+	ttls := map[string]time.Duration{"Tom": time.Minute, "Jack": -1}
+	gee := NewGroup("scores-ttl", 2<<10, TTLGetterFunc(
+		func(key string) ([]byte, time.Duration, error) {
+			loads[key]++
+			return []byte(db[key]), ttls[key], nil
+		},
+	))
+	gee.SetTTL(time.Second)
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	gee.mainCache.clock = clock
+	var expired []string
+	gee.SetOnEvicted(func(key string, value ByteView, reason lru.EvictReason) {
+		if reason == lru.EvictExpired {
+			expired = append(expired, key)
+		}
+	})
+
+	for k := range db {
+		gee.Get(k)
+	}
+	clock.Advance(2 * time.Second) // Sam 使用默认 TTL 过期
+	for k := range db {
+		gee.Get(k)
+	}
+	if loads["Sam"] != 2 || loads["Tom"] != 1 || loads["Jack"] != 1 {
+		t.Fatalf("unexpected loads after default ttl: %v", loads)
+	}
+
+	clock.Advance(time.Hour) // Tom 的 TTL 是 1 分钟，Jack 不过期
+	if n := gee.mainCache.removeExpired(); n != 2 {
+		t.Fatalf("janitor reclaimed %d entries, expect 2", n)
+	}
+	if _, ok := gee.mainCache.get("Jack"); !ok {
+		t.Fatalf("Jack without ttl should stay in cache")
+	}
+	if len(expired) != 3 {
+		t.Fatalf("OnEvicted expired keys = %v, expect Sam twice and Tom", expired)
+	}
+}
+
+type fakePeer struct {
+	gets    map[string]int
+	sets    map[string]string
+	removes []string
+}
+
+func newFakePeer() *fakePeer {
+	return &fakePeer{gets: make(map[string]int), sets: make(map[string]string)}
+}
+
+func (p *fakePeer) Get(in *pb.Request, out *pb.Response) error {
+	p.gets[in.Key]++
+	v, ok := db[in.Key]
+	if !ok {
+		out.Code = pb.Code_NOT_FOUND
+		return responseError(out)
+	}
+	out.Value = []byte(v)
+	return nil
+}
+
+func (p *fakePeer) Set(in *pb.SetRequest, out *pb.Response) error {
+	p.sets[in.Key] = string(in.Value)
+	return nil
+}
+
+func (p *fakePeer) Remove(in *pb.Request, out *pb.Response) error {
+	p.removes = append(p.removes, in.Key)
+	return nil
+}
+
+// fakePicker 把 owned 中的 key 分配给 peers[0]，其余的 key 属于自己
+type fakePicker struct {
+	peers []*fakePeer
+	owned map[string]bool
+}
+
+func (p fakePicker) PickPeer(key string) (PeerGetter, bool) {
+	if p.owned != nil && !p.owned[key] {
+		return nil, false
+	}
+	return p.peers[0], true
+}
+
+func (p fakePicker) GetAll() []PeerGetter {
+	all := make([]PeerGetter, len(p.peers))
+	for i, peer := range p.peers {
+		all[i] = peer
+	}
+	return all
+}
+
+func TestHotCache(t *testing.T) {
+	peer := newFakePeer()
+	gee := NewGroup("scores-hot", 0, GetterFunc(func(key string) ([]byte, error) {
+		t.Fatalf("key %s owned by peer should not be loaded locally", key)
+		return nil, nil
+	}))
+	gee.RegisterPeers(fakePicker{peers: []*fakePeer{peer}})
+	gee.hotChance = 1
+
+	for i := 0; i < 3; i++ {
+		if view, err := gee.Get("Tom"); err != nil || view.String() != db["Tom"] {
+			t.Fatalf("failed to get Tom from peer")
+		}
+	}
+	if peer.gets["Tom"] != 1 {
+		t.Fatalf("hot key fetched from peer %d times, expect 1", peer.gets["Tom"])
+	}
+	if _, ok := gee.mainCache.get("Tom"); ok {
+		t.Fatalf("peer-owned key should not be stored in mainCache")
+	}
+}
+
+func TestHotCacheBalance(t *testing.T) {
+	// 每个条目 key 4 字节 + value 4 字节，容量 80 字节，hotCache 最多 16 字节
+	gee := NewGroup("scores-balance", 80, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("vvvv"), nil
+	}))
+	gee.SetHotRatio(0.2)
+	for i := 0; i < 5; i++ {
+		gee.populateCache(fmt.Sprintf("hot%d", i), ByteView{b: []byte("vvvv")}, 0, &gee.hotCache)
+	}
+	for i := 0; i < 10; i++ {
+		gee.populateCache(fmt.Sprintf("mai%d", i), ByteView{b: []byte("vvvv")}, 0, &gee.mainCache)
+	}
+	if hot := gee.hotCache.bytes(); hot > 16 {
+		t.Fatalf("hotCache uses %d bytes, expect at most 16", hot)
+	}
+	if total := gee.mainCache.bytes() + gee.hotCache.bytes(); total > 80 {
+		t.Fatalf("caches use %d bytes, expect at most 80", total)
+	}
+	if _, ok := gee.mainCache.get("mai9"); !ok {
+		t.Fatalf("newest main entry should not be evicted")
+	}
+}
+
+func TestSetRemove(t *testing.T) {
+	owner, other := newFakePeer(), newFakePeer()
+	gee := NewGroup("scores-set", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(db[key]), nil
+	}))
+	gee.RegisterPeers(fakePicker{peers: []*fakePeer{owner, other}, owned: map[string]bool{"Tom": true}})
+	gee.hotChance = 1
+
+	// Sam 属于自己：写入 mainCache，并让其他节点删除 hotCache 中的旧值
+	if err := gee.Set("Sam", []byte("600"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := gee.Get("Sam"); err != nil || view.String() != "600" {
+		t.Fatalf("Set Sam failed, got %v %v", view, err)
+	}
+	if len(owner.removes) != 1 || len(other.removes) != 1 {
+		t.Fatalf("Set should broadcast remove to all peers, got %v %v", owner.removes, other.removes)
+	}
+
+	// Tom 属于 owner：写入 owner，本地 hotCache 中的旧值被删除，只通知其他节点
+	gee.Get("Tom")
+	if err := gee.Set("Tom", []byte("700"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if owner.sets["Tom"] != "700" || len(owner.removes) != 1 || len(other.removes) != 2 {
+		t.Fatalf("Set Tom should update owner only, sets=%v removes=%v %v", owner.sets, owner.removes, other.removes)
+	}
+	if _, ok := gee.hotCache.get("Tom"); ok {
+		t.Fatalf("stale Tom should be removed from hotCache")
+	}
+
+	if err := gee.Remove("Sam"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gee.mainCache.get("Sam"); ok {
+		t.Fatalf("Remove should purge Sam locally")
+	}
+	if len(owner.removes) != 2 || len(other.removes) != 3 {
+		t.Fatalf("Remove should broadcast to all peers, got %v %v", owner.removes, other.removes)
+	}
+}
+
+// 测试 WithPolicy：mainCache 和 hotCache 使用指定的淘汰策略，mainCache 按 cacheBytes 限制容量
+func TestWithPolicy(t *testing.T) {
+	gee := NewGroup("policy", 40, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithPolicy(policy.NewLFU), WithTTL(time.Minute))
+	for i := 0; i < 10; i++ {
+		if _, err := gee.Get(fmt.Sprintf("key%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := gee.mainCache.shards[0].policy.(*policy.LFU); !ok {
+		t.Fatalf("mainCache policy = %T, want *policy.LFU", gee.mainCache.shards[0].policy)
+	}
+	if n := gee.mainCache.bytes(); n > 40 {
+		t.Fatalf("mainCache uses %d bytes, want <= 40", n)
+	}
+	if gee.ttl != time.Minute {
+		t.Fatalf("WithTTL not applied")
+	}
+}
+
+// 测试否定缓存：ErrNotFound 在 negTTL 内只回源一次，其他错误不缓存，Set 会清除否定条目
+func TestNegativeCache(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	loads := make(map[string]int)
+	gee := NewGroup("negative", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads[key]++
+		if key == "broken" {
+			return nil, fmt.Errorf("db unavailable")
+		}
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, &NotFoundError{Key: key}
+	}), WithNegativeCache(time.Second, 1<<10))
+	gee.negCache.clock = clock
+
+	for i := 0; i < 3; i++ {
+		if _, err := gee.Get("unknown"); !IsNotFound(err) {
+			t.Fatalf("expect not found, got %v", err)
+		}
+		if _, err := gee.Get("broken"); err == nil || IsNotFound(err) {
+			t.Fatalf("expect db error, got %v", err)
+		}
+	}
+	if loads["unknown"] != 1 || loads["broken"] != 3 {
+		t.Fatalf("loads = %v, want unknown once and broken every time", loads)
+	}
+	if s := gee.Stats(); s.NegativeHits != 2 || s.NegCache.Items != 1 || s.NegCache.Bytes != int64(len("unknown")) || s.LocalErrors != 3 || s.LocalLoads != 1 {
+		t.Fatalf("unexpected negative stats: %+v", s)
+	}
+
+	clock.Advance(2 * time.Second)
+	gee.Get("unknown")
+	if loads["unknown"] != 2 {
+		t.Fatalf("negative entry should expire, loads = %d", loads["unknown"])
+	}
+
+	if err := gee.Set("unknown", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := gee.Get("unknown"); err != nil || view.String() != "1" {
+		t.Fatalf("Set should clear the negative entry, got %v %v", view, err)
+	}
+
+	// owner 返回 NOT_FOUND 时不回源，结果同样被缓存
+	peer := newFakePeer()
+	gee.RegisterPeers(fakePicker{peers: []*fakePeer{peer}})
+	for i := 0; i < 2; i++ {
+		if _, err := gee.Get("nobody"); !IsNotFound(err) {
+			t.Fatalf("expect not found from peer, got %v", err)
+		}
+	}
+	if peer.gets["nobody"] != 1 || loads["nobody"] != 0 {
+		t.Fatalf("peer gets = %d, local loads = %d, want 1 and 0", peer.gets["nobody"], loads["nobody"])
+	}
+	// 写到 owner 之后本地的否定条目也要清除
+	if err := gee.Set("nobody", []byte("2"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gee.negCache.get("nobody"); ok || peer.sets["nobody"] != "2" {
+		t.Fatalf("Set through peer should clear the negative entry")
+	}
+
+	// cacheBytes 为 0 时否定条目仍然有容量上限
+	unbounded := NewGroup("negative-unbounded", 0, GetterFunc(func(key string) ([]byte, error) {
+		return nil, &NotFoundError{Key: key}
+	}))
+	if unbounded.negCache.cacheBytes != defaultNegativeBytes {
+		t.Fatalf("negCache capacity = %d, want %d", unbounded.negCache.cacheBytes, defaultNegativeBytes)
+	}
+}
+
+// 测试 GetContext：ctx 超时的调用者直接返回，加载继续执行并写入缓存
+func TestGetContext(t *testing.T) {
+	release := make(chan struct{})
+	gee := NewGroup("context", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		<-release
+		return []byte(db[key]), nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := gee.GetContext(ctx, "Tom"); err != context.DeadlineExceeded {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+	close(release)
+	if view, err := gee.Get("Tom"); err != nil || view.String() != db["Tom"] {
+		t.Fatalf("Get after timeout = %v, %v", view, err)
+	}
+}
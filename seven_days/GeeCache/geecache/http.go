@@ -2,18 +2,31 @@ package geecache
 
 import (
 	"GeeCache/geecache/consistenthash"
+	pb "GeeCache/geecache/geecachepb"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+
+	"google.golang.org/protobuf/proto"
 )
 
 const (
 	defaultBasePath = "/_geecache"
 	defaultReplicas = 50 // 虚拟节点个数
+
+	// 请求头 Accept 包含 protoContentType 时返回 protobuf 编码的 pb.Response，否则返回原始字节
+	protoContentType = "application/x-protobuf"
+	rawContentType   = "application/octet-stream"
+
+	statsPath   = "_stats"   // /<basepath>/_stats 返回所有 Group 的统计（JSON）
+	metricsPath = "_metrics" // /<basepath>/_metrics 返回 Prometheus 文本格式的统计
 )
 
 /*
@@ -52,6 +65,11 @@ func (p *HTTPPool) Log(format string, v ...interface{}) {
    1、ServeHTTP 的实现逻辑是比较简单的，首先判断访问路径的前缀是否是 basePath，不是返回错误。
    2、我们约定访问路径格式为 /<basepath>/<groupname>/<key>，通过 groupname 得到 group 实例，再使用 group.Get(key) 获取缓存数据。
    3、最终使用 w.Write() 将缓存值作为 httpResponse 的 body 返回。
+   4、请求的 Accept 包含 application/x-protobuf 时，返回 protobuf 编码的 pb.Response，错误也编码在 Code 中；
+   	否则按旧的格式返回原始字节，兼容旧版本的节点。
+   5、PUT 写入 key，请求体是 protobuf 编码的 pb.SetRequest，或者是原始字节（过期时间由查询参数 ttl_ms 指定，含义与 pb.SetRequest 相同）；
+   	DELETE 从本节点的 mainCache 和 hotCache 中删除 key。
+   6、/<basepath>/_stats 和 /<basepath>/_metrics 返回统计，见 stats.go。
 
 */
 func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -59,10 +77,22 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		panic("HTTPPool serving unexpected path: " + r.URL.Path)
 	}
 	p.Log("%s %s", r.Method, r.URL.Path)
+	useProto := strings.Contains(r.Header.Get("Accept"), protoContentType)
+	rest := strings.TrimPrefix(r.URL.Path[len(p.basePath):], "/")
+	switch rest {
+	case statsPath:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AllStats())
+		return
+	case metricsPath:
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WritePrometheus(w, AllStats())
+		return
+	}
 	// /<basepath>/<groupname>/<key> required
-	parts := strings.SplitN(r.URL.Path[len(p.basePath)+1:], "/", 2)
+	parts := strings.SplitN(rest, "/", 2)
 	if len(parts) != 2 {
-		http.Error(w, "bad request", http.StatusBadRequest)
+		writeError(w, useProto, http.StatusBadRequest, pb.Code_BAD_REQUEST, "bad request")
 		return
 	}
 	groupName := parts[0]
@@ -70,18 +100,88 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	group := GetGroup(groupName)
 	if group == nil {
-		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
+		writeError(w, useProto, http.StatusNotFound, pb.Code_NO_SUCH_GROUP, "no such group: "+groupName)
 		return
 	}
 
-	view, err := group.Get(key)
+	switch r.Method {
+	case http.MethodPut:
+		p.serveSet(w, r, useProto, group, key)
+		return
+	case http.MethodDelete:
+		group.removeLocally(key)
+		writeOK(w, useProto)
+		return
+	}
+
+	view, err := group.GetContext(r.Context(), key) // 客户端断开后不再等待加载
+	if IsNotFound(err) {
+		writeError(w, useProto, http.StatusNotFound, pb.Code_NOT_FOUND, err.Error())
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, useProto, http.StatusInternalServerError, pb.Code_INTERNAL, err.Error())
+		return
+	}
+
+	if !useProto {
+		w.Header().Set("Content-Type", rawContentType)
+		w.Write(view.ByteSlice())
 		return
 	}
+	writeProto(w, http.StatusOK, &pb.Response{
+		Value: view.ByteSlice(),
+		TtlMs: ttlToMs(group.ttlOf(key)),
+	})
+}
 
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Write(view.ByteSlice())
+func (p *HTTPPool) serveSet(w http.ResponseWriter, r *http.Request, useProto bool, group *Group, key string) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, useProto, http.StatusBadRequest, pb.Code_BAD_REQUEST, err.Error())
+		return
+	}
+	req := &pb.SetRequest{Value: body}
+	if r.Header.Get("Content-Type") == protoContentType {
+		if err := proto.Unmarshal(body, req); err != nil {
+			writeError(w, useProto, http.StatusBadRequest, pb.Code_BAD_REQUEST, err.Error())
+			return
+		}
+	} else if ttl := r.URL.Query().Get("ttl_ms"); ttl != "" {
+		if req.TtlMs, err = strconv.ParseInt(ttl, 10, 64); err != nil {
+			writeError(w, useProto, http.StatusBadRequest, pb.Code_BAD_REQUEST, "invalid ttl_ms: "+ttl)
+			return
+		}
+	}
+	group.setLocally(key, ByteView{b: req.Value}, msToTTL(req.TtlMs))
+	writeOK(w, useProto)
+}
+
+func writeOK(w http.ResponseWriter, useProto bool) {
+	if useProto {
+		writeProto(w, http.StatusOK, &pb.Response{})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, useProto bool, status int, code pb.Code, message string) {
+	if !useProto {
+		http.Error(w, message, status)
+		return
+	}
+	writeProto(w, status, &pb.Response{Code: code, Message: message})
+}
+
+func writeProto(w http.ResponseWriter, status int, res *pb.Response) {
+	body, err := proto.Marshal(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", protoContentType)
+	w.WriteHeader(status)
+	w.Write(body)
 }
 
 // Set 一致性 hash 实例，将节点加入环中
@@ -108,6 +208,19 @@ func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 	return nil, false
 }
 
+// GetAll 返回除自己以外的所有节点
+func (p *HTTPPool) GetAll() []PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	peers := make([]PeerGetter, 0, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		if peer != p.self {
+			peers = append(peers, getter)
+		}
+	}
+	return peers
+}
+
 // 这是确保接口被实现常用的方式。即利用强制类型转换，确保 struct HTTPPool 实现了接口 PeerPicker。
 // 这样 IDE 和编译期间就可以检查，而不是等到使用的时候。
 var _ PeerPicker = (*HTTPPool)(nil)
@@ -117,28 +230,73 @@ type httpGetter struct {
 	baseURL string // 例如 http://example.com/_geecache/
 }
 
-func (h *httpGetter) Get(group string, key string) ([]byte, error) {
-	u := fmt.Sprintf(
-		"%v%v%v",
+func (h *httpGetter) url(group, key string) string {
+	return fmt.Sprintf(
+		"%v/%v/%v",
 		h.baseURL,
-		url.QueryEscape(group), // QueryEscape函数对s进行转码使之可以安全的用在URL查询里。
-		url.QueryEscape(key),
+		url.PathEscape(group), // PathEscape 对 s 进行转码使之可以安全的用在 URL 路径里。
+		url.PathEscape(key),
 	)
-	res, err := http.Get(u)
+}
+
+// Get 请求 protobuf 格式的响应，对端是只支持原始字节的旧版本时按原始字节处理
+func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
+	req, err := http.NewRequest(http.MethodGet, h.url(in.GetGroup(), in.GetKey()), nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer res.Body.Close()
+	return h.do(req, out)
+}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned: %v", res.Status)
+// Set 把 key 写入对端的 mainCache
+func (h *httpGetter) Set(in *pb.SetRequest, out *pb.Response) error {
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
 	}
+	req, err := http.NewRequest(http.MethodPut, h.url(in.GetGroup(), in.GetKey()), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", protoContentType)
+	return h.do(req, out)
+}
+
+// Remove 从对端的 mainCache 和 hotCache 中删除 key
+func (h *httpGetter) Remove(in *pb.Request, out *pb.Response) error {
+	req, err := http.NewRequest(http.MethodDelete, h.url(in.GetGroup(), in.GetKey()), nil)
+	if err != nil {
+		return err
+	}
+	return h.do(req, out)
+}
 
-	bytes, err := ioutil.ReadAll(res.Body)
+func (h *httpGetter) do(req *http.Request, out *pb.Response) error {
+	req.Header.Set("Accept", protoContentType+", "+rawContentType+";q=0.5")
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("reading response body: %v", err)
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+
+	// 旧版本的节点只在 group 不存在时返回 404，不能当作 key 不存在，由 Group 回退到本地加载
+	if res.Header.Get("Content-Type") != protoContentType {
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("server returned: %v", res.Status)
+		}
+		out.Reset()
+		out.Value = body
+		return nil
+	}
+	if err := proto.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
 	}
-	return bytes, nil
+	return responseError(out)
 }
 
 var _ PeerGetter = (*httpGetter)(nil)
@@ -0,0 +1,150 @@
+package geecache
+
+import (
+	pb "GeeCache/geecache/geecachepb"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPPoolProtocol(t *testing.T) {
+	g := NewGroup("scores-http", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, &NotFoundError{Key: key}
+	}))
+	g.SetTTL(time.Minute)
+	srv := httptest.NewServer(NewHTTPPool("test"))
+	defer srv.Close()
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	res := &pb.Response{}
+	if err := getter.Get(&pb.Request{Group: "scores-http", Key: "Tom"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != db["Tom"] || res.TtlMs <= 0 || res.TtlMs > time.Minute.Milliseconds() {
+		t.Fatalf("unexpected protobuf response: %v", res)
+	}
+
+	if err := getter.Get(&pb.Request{Group: "scores-http", Key: "unknown"}, res); !IsNotFound(err) || res.Code != pb.Code_NOT_FOUND {
+		t.Fatalf("expect NOT_FOUND for missing key, got %v %v", err, res.Code)
+	}
+
+	err := getter.Get(&pb.Request{Group: "unknown", Key: "Tom"}, res)
+	if err == nil || IsNotFound(err) || res.Code != pb.Code_NO_SUCH_GROUP {
+		t.Fatalf("expect NO_SUCH_GROUP for unknown group, got %v %v", err, res.Code)
+	}
+
+	if err := getter.Set(&pb.SetRequest{Group: "scores-http", Key: "Tom", Value: []byte("700")}, res); err != nil {
+		t.Fatal(err)
+	}
+	if view, _ := g.Get("Tom"); view.String() != "700" {
+		t.Fatalf("PUT should update Tom, got %s", view)
+	}
+	if err := getter.Remove(&pb.Request{Group: "scores-http", Key: "Tom"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.mainCache.get("Tom"); ok {
+		t.Fatalf("DELETE should remove Tom")
+	}
+
+	// 不带 Accept 的旧客户端仍然得到原始字节
+	raw, err := http.Get(srv.URL + defaultBasePath + "/scores-http/Tom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Body.Close()
+	body, _ := ioutil.ReadAll(raw.Body)
+	if !strings.HasPrefix(raw.Header.Get("Content-Type"), rawContentType) || string(body) != db["Tom"] {
+		t.Fatalf("unexpected raw response: %s %q", raw.Header.Get("Content-Type"), body)
+	}
+
+	raw404, err := http.Get(srv.URL + defaultBasePath + "/scores-http/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw404.Body.Close()
+	if raw404.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing key should return 404, got %v", raw404.Status)
+	}
+}
+
+// owner 没有注册该 group，或者是只在 group 不存在时返回 404 的旧版本节点，都不能当作 key 不存在
+func TestHTTPPeerFallback(t *testing.T) {
+	servers := map[string]http.Handler{
+		"no-such-group": NewHTTPPool("peer"),
+		"raw-404":       http.NotFoundHandler(),
+	}
+	for name, handler := range servers {
+		srv := httptest.NewServer(handler)
+		loads := 0
+		g := NewGroup("fallback-"+name, 2<<10, GetterFunc(func(key string) ([]byte, error) {
+			loads++
+			return []byte(db[key]), nil
+		}))
+		// 从全局表中删除，对端的 HTTPPool 就找不到这个 group
+		mu.Lock()
+		delete(groups, g.name)
+		mu.Unlock()
+		pool := NewHTTPPool("self")
+		pool.Set(srv.URL)
+		g.RegisterPeers(pool)
+
+		if view, err := g.Get("Tom"); err != nil || view.String() != db["Tom"] {
+			t.Fatalf("%s: expect local fallback, got %q %v", name, view.String(), err)
+		}
+		if s := g.Stats(); loads != 1 || s.PeerErrors != 1 || s.LocalLoads != 1 || s.NegCache.Items != 0 {
+			t.Fatalf("%s: loads = %d, stats = %+v", name, loads, s)
+		}
+		srv.Close()
+	}
+}
+
+// owner 是远端节点时，不过期和不足 1ms 的 ttl 不能在传输中变成默认 TTL
+func TestHTTPPeerTTL(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(db[key]), nil
+	})
+	local := NewGroup("scores-ttl", 2<<10, getter)
+	local.SetTTL(time.Minute)
+	local.hotChance = 1
+	// 同名的 owner 替换全局表中的 local，对端的 HTTPPool 找到的是 owner
+	owner := NewGroup("scores-ttl", 2<<10, getter)
+	owner.SetTTL(time.Minute)
+	srv := httptest.NewServer(NewHTTPPool("owner"))
+	defer srv.Close()
+	pool := NewHTTPPool("self")
+	pool.Set(srv.URL)
+	local.RegisterPeers(pool)
+
+	cases := []struct {
+		key      string
+		ttl      time.Duration
+		noExpiry bool
+	}{
+		{"Tom", -1, true},
+		{"Jack", -time.Microsecond * 500, true},
+		{"Sam", time.Microsecond * 500, false},
+	}
+	for _, tc := range cases {
+		if err := local.Set(tc.key, []byte("700"), tc.ttl); err != nil {
+			t.Fatal(err)
+		}
+		expires, ok := owner.mainCache.expires(tc.key)
+		if !ok || expires.IsZero() != tc.noExpiry || (!tc.noExpiry && time.Until(expires) > time.Second) {
+			t.Fatalf("Set(%s, %v): owner expires = %v, %v", tc.key, tc.ttl, expires, ok)
+		}
+	}
+
+	// 从 owner 取回不过期的 key，hotCache 中也不过期，而不是使用 local 的默认 TTL
+	if view, err := local.Get("Tom"); err != nil || view.String() != "700" {
+		t.Fatalf("Get Tom = %q, %v", view.String(), err)
+	}
+	if expires, ok := local.hotCache.expires("Tom"); !ok || !expires.IsZero() {
+		t.Fatalf("hot Tom expires = %v, %v; want no expiry", expires, ok)
+	}
+}
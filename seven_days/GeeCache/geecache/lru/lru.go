@@ -1,6 +1,9 @@
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"time"
+)
 
 /*
 
@@ -9,21 +12,61 @@ import "container/list"
    3、maxBytes 是允许使用的最大内存，nbytes 是当前已使用的内存，OnEvicted 是某条记录被移除时的回调函数，可以为 nil。
    4、键值对 entry 是双向链表节点的数据类型，在链表中仍保存每个值对应的 key 的好处在于，淘汰队首节点时，需要用 key 从字典中删除对应的映射。
    5、为了通用性，我们允许值是实现了 Value 接口的任意类型，该接口只包含了一个方法 Len() int，用于返回值所占用的内存大小。
+   6、每个 entry 可以带有过期时间，Get 时发现过期会惰性删除，RemoveExpired 用于后台定期回收过期条目占用的内存。
 
 */
 
+// EvictReason 条目被移除的原因
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // 超过 maxBytes 被淘汰
+	EvictExpired                     // 过期
+	EvictRemoved                     // 调用 Remove 删除
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Clock 提供当前时间，测试时可以替换为假时钟
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock 使用 time.Now 的 Clock
+var SystemClock Clock = systemClock{}
+
 // LRU Cache（最近最少使用）
 type Cache struct {
 	maxBytes  int64
 	nbytes    int64
 	ll        *list.List // 双向链表
 	cache     map[string]*list.Element
-	OnEvicted func(key string, value Value) // hook 在清除条目时执行。
+	OnEvicted func(key string, value Value, reason EvictReason) // hook 在清除条目时执行。
+	Clock     Clock                                             // 判断过期使用的时钟，默认 SystemClock
 }
 
 type entry struct {
-	key   string
-	value Value
+	key     string
+	value   Value
+	expires time.Time // 零值表示不过期
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
 }
 
 // Value 用来计算字节数
@@ -31,12 +74,13 @@ type Value interface {
 	Len() int
 }
 
-func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+func New(maxBytes int64, onEvicted func(string, Value, EvictReason)) *Cache {
 	return &Cache{
 		maxBytes:  maxBytes,
 		ll:        list.New(),
 		cache:     make(map[string]*list.Element),
 		OnEvicted: onEvicted,
+		Clock:     SystemClock,
 	}
 }
 
@@ -44,11 +88,16 @@ func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
 
    1、如果键对应的链表节点存在，则将对应节点移动到队尾，并返回查找到的值。
    2、c.ll.MoveToFront(ele)，即将链表中的节点 ele 移动到队尾（双向链表作为队列，队首队尾是相对的，在这里约定 front 为队尾）
+   3、节点已经过期时直接删除，按未命中处理。
 
 */
 // Get 查找键的值
 func (c *Cache) Get(key string) (value Value, ok bool) {
 	if ele, ok := c.cache[key]; ok {
+		if ele.Value.(*entry).expired(c.Clock.Now()) {
+			c.removeElement(ele, EvictExpired)
+			return nil, false
+		}
 		c.ll.MoveToFront(ele)
 		kv := ele.Value.(*entry)
 		return kv.value, true
@@ -56,6 +105,26 @@ func (c *Cache) Get(key string) (value Value, ok bool) {
 	return
 }
 
+// Peek 查找键的值，不改变条目的访问顺序，也不删除过期条目，可以在读锁下调用
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if kv.expired(c.Clock.Now()) {
+			return nil, false
+		}
+		return kv.value, true
+	}
+	return
+}
+
+// Expires 返回键的过期时间，零值表示不过期，不会改变条目的访问顺序
+func (c *Cache) Expires(key string) (time.Time, bool) {
+	if ele, ok := c.cache[key]; ok {
+		return ele.Value.(*entry).expires, true
+	}
+	return time.Time{}, false
+}
+
 /*
 
    1、c.ll.Back() 取到队首节点，从链表中删除。
@@ -68,13 +137,41 @@ func (c *Cache) Get(key string) (value Value, ok bool) {
 func (c *Cache) RemoveOldest() {
 	ele := c.ll.Back()
 	if ele != nil {
-		c.ll.Remove(ele)
-		kv := ele.Value.(*entry)
-		delete(c.cache, kv.key)
-		c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
-		if c.OnEvicted != nil {
-			c.OnEvicted(kv.key, kv.value)
+		c.removeElement(ele, EvictCapacity)
+	}
+}
+
+// Remove 删除键，键不存在时返回 false
+func (c *Cache) Remove(key string) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele, EvictRemoved)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired 删除所有过期的条目，返回删除的个数
+func (c *Cache) RemoveExpired() int {
+	now := c.Clock.Now()
+	n := 0
+	for ele := c.ll.Back(); ele != nil; {
+		prev := ele.Prev()
+		if ele.Value.(*entry).expired(now) {
+			c.removeElement(ele, EvictExpired)
+			n++
 		}
+		ele = prev
+	}
+	return n
+}
+
+func (c *Cache) removeElement(ele *list.Element, reason EvictReason) {
+	c.ll.Remove(ele)
+	kv := ele.Value.(*entry)
+	delete(c.cache, kv.key)
+	c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value, reason)
 	}
 }
 
@@ -85,16 +182,26 @@ func (c *Cache) RemoveOldest() {
    3、更新 c.nbytes，如果超过了设定的最大值 c.maxBytes，则移除最少访问的节点。
 
 */
-// Add 新增缓存
+// Add 新增缓存，不过期
 func (c *Cache) Add(key string, value Value) {
+	c.AddWithTTL(key, value, 0)
+}
+
+// AddWithTTL 新增缓存，ttl <= 0 表示不过期
+func (c *Cache) AddWithTTL(key string, value Value, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.Clock.Now().Add(ttl)
+	}
 	if ele, ok := c.cache[key]; ok {
 		c.ll.MoveToFront(ele)
 		kv := ele.Value.(*entry)
 		c.nbytes += int64(value.Len()) - int64(kv.value.Len()) // value 替换了
 		kv.value = value
+		kv.expires = expires
 	} else {
 		// 新增节点
-		ele := c.ll.PushFront(&entry{key: key, value: value})
+		ele := c.ll.PushFront(&entry{key: key, value: value, expires: expires})
 		c.cache[key] = ele
 		c.nbytes += int64(value.Len()) + int64(len(key))
 	}
@@ -103,7 +210,12 @@ func (c *Cache) Add(key string, value Value) {
 	}
 }
 
-// Len 缓存数
+// Len 缓存数，包括尚未回收的过期条目
 func (c *Cache) Len() int {
 	return c.ll.Len()
 }
+
+// Bytes 当前使用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
@@ -3,6 +3,7 @@ package lru
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -40,7 +41,7 @@ func TestRemoveoldest(t *testing.T) {
 // 测试回调函数能否被调用
 func TestOnEvicted(t *testing.T) {
 	keys := make([]string, 0)
-	callback := func(key string, value Value) {
+	callback := func(key string, value Value, reason EvictReason) {
 		keys = append(keys, key)
 	}
 	lru := New(int64(10), callback)
@@ -55,3 +56,69 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time { return f.now }
+
+func (f *fakeClock) Advance(d time.Duration) { f.now = f.now.Add(d) }
+
+func TestTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	reasons := make(map[string]EvictReason)
+	lru := New(int64(0), func(key string, value Value, reason EvictReason) {
+		reasons[key] = reason
+	})
+	lru.Clock = clock
+	lru.AddWithTTL("k1", String("v1"), time.Second)
+	lru.AddWithTTL("k2", String("v2"), 3*time.Second)
+	lru.Add("k3", String("v3"))
+
+	clock.Advance(time.Second)
+	if _, ok := lru.Get("k1"); ok {
+		t.Fatalf("k1 should expire after 1s")
+	}
+	if _, ok := lru.Get("k2"); !ok {
+		t.Fatalf("k2 should not expire after 1s")
+	}
+	if reasons["k1"] != EvictExpired || lru.Len() != 2 {
+		t.Fatalf("lazy expiry failed, reasons=%v len=%d", reasons, lru.Len())
+	}
+
+	// 重新写入会刷新过期时间
+	lru.AddWithTTL("k2", String("v2"), 3*time.Second)
+	clock.Advance(2 * time.Second)
+	if _, ok := lru.Get("k2"); !ok {
+		t.Fatalf("k2 ttl should be refreshed by Add")
+	}
+
+	clock.Advance(time.Hour)
+	if n := lru.RemoveExpired(); n != 1 {
+		t.Fatalf("RemoveExpired removed %d entries, expect 1", n)
+	}
+	if lru.Len() != 1 || lru.Bytes() != int64(len("k3")+len("v3")) {
+		t.Fatalf("expired bytes not reclaimed, len=%d bytes=%d", lru.Len(), lru.Bytes())
+	}
+	if _, ok := lru.Get("k3"); !ok {
+		t.Fatalf("k3 without ttl should never expire")
+	}
+}
+
+func TestEvictReason(t *testing.T) {
+	reasons := make(map[string]EvictReason)
+	lru := New(int64(8), func(key string, value Value, reason EvictReason) {
+		reasons[key] = reason
+	})
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+	lru.Remove("k3")
+
+	expect := map[string]EvictReason{"k1": EvictCapacity, "k3": EvictRemoved}
+	if !reflect.DeepEqual(expect, reasons) {
+		t.Fatalf("evict reasons = %v, expect %v", reasons, expect)
+	}
+}
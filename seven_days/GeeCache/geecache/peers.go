@@ -1,18 +1,41 @@
 package geecache
 
+import (
+	pb "GeeCache/geecache/geecachepb"
+	"fmt"
+)
+
 /*
 
    1、在这里，抽象出 2 个接口，PeerPicker 的 PickPeer() 方法用于根据传入的 key 选择相应节点 PeerGetter。
    2、接口 PeerGetter 的 Get() 方法用于从对应 group 查找缓存值。PeerGetter 就对应于上述流程中的 HTTP 客户端。
+   3、节点之间使用 geecachepb 中的 Request/Response 通信，Response 除了值之外还带有 TTL、版本号和错误码。
+   4、Set 更新 key 所在节点的 mainCache，Remove 删除对端 mainCache 和 hotCache 中的 key，用于数据源变化后的失效广播。
+   5、key 不存在时对端返回 Code_NOT_FOUND（HTTP 为 404），PeerGetter 返回的错误可以用 IsNotFound 判断。
+   	对端没有注册该 group 时返回 Code_NO_SUCH_GROUP，和其他错误一样由 Group 回退到本地加载。
 
 */
 
 // PeerPicker 是定位时必须实现的接口 (server 查找key在哪个机器上)
 type PeerPicker interface {
 	PickPeer(key string) (peer PeerGetter, ok bool)
+	GetAll() []PeerGetter // 除自己以外的所有节点
 }
 
 // PeerGetter是必须由 peer 实现的接口 -> httppool (client 发送http请求获取key)
 type PeerGetter interface {
-	Get(group string, key string) ([]byte, error)
+	Get(in *pb.Request, out *pb.Response) error
+	Set(in *pb.SetRequest, out *pb.Response) error
+	Remove(in *pb.Request, out *pb.Response) error
+}
+
+// responseError 把 Response.Code 转换为 error，只有 NOT_FOUND 包装 ErrNotFound
+func responseError(out *pb.Response) error {
+	switch out.Code {
+	case pb.Code_OK:
+		return nil
+	case pb.Code_NOT_FOUND:
+		return fmt.Errorf("server returned: %s: %w", out.Message, ErrNotFound)
+	}
+	return fmt.Errorf("server returned: %v %s", out.Code, out.Message)
 }
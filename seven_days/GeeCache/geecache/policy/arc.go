@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"GeeCache/geecache/lru"
+	"time"
+)
+
+/*
+
+   ARC（Adaptive Replacement Cache，Megiddo & Modha）：
+   1、T1 保存只访问过一次的条目，T2 保存访问过多次的条目，命中 T1 的条目会移动到 T2。
+   2、B1、B2 分别记录从 T1、T2 淘汰的 key（ghost，不保存值）。
+   3、新增的 key 命中 B1 说明 T1 太小，增大 T1 的目标大小 p；命中 B2 则减小 p。
+   4、淘汰时 T1 超过 p 就淘汰 T1，否则淘汰 T2，从而在“最近”和“经常”之间自适应。
+   5、这里的容量和 p 都按字节计算。
+
+*/
+
+type ARC struct {
+	segmented
+	p      int64 // T1 的目标大小
+	t1, t2 *segment
+	b1, b2 *ghosts
+}
+
+func NewARC(opts Options) Policy {
+	return &ARC{
+		segmented: newSegmented(opts),
+		t1:        newSegment(),
+		t2:        newSegment(),
+		b1:        newGhosts(),
+		b2:        newGhosts(),
+	}
+}
+
+func (c *ARC) Get(key string) (lru.Value, bool) {
+	ele, ok := c.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	ele = c.move(ele, c.t2)
+	return ele.Value.(*entry).value, true
+}
+
+func (c *ARC) AddWithTTL(key string, value lru.Value, ttl time.Duration) {
+	if ele, ok := c.items[key]; ok {
+		c.updateIn(ele, value, c.deadline(ttl))
+		c.move(ele, c.t2)
+		c.balance()
+		return
+	}
+	e := newEntry(key, value, c.deadline(ttl))
+	switch {
+	case c.b1.has(key):
+		c.p = min64(c.p+max64(c.b2.seg.bytes/max64(c.b1.seg.bytes, 1), 1)*e.bytes, c.maxBytes)
+		c.b1.take(key)
+		c.insert(c.t2, e)
+	case c.b2.has(key):
+		c.p = max64(c.p-max64(c.b1.seg.bytes/max64(c.b2.seg.bytes, 1), 1)*e.bytes, 0)
+		c.b2.take(key)
+		c.insert(c.t2, e)
+	default:
+		c.insert(c.t1, e)
+	}
+	c.balance()
+}
+
+// balance 淘汰超出容量的条目，并限制 ghost 的大小：T1+B1 不超过容量，全部不超过两倍容量
+func (c *ARC) balance() {
+	for c.overflow() {
+		c.RemoveOldest()
+	}
+	for c.t1.bytes+c.b1.seg.bytes > c.maxBytes && c.b1.seg.len() > 0 {
+		c.b1.removeOldest()
+	}
+	for c.nbytes+c.b1.seg.bytes+c.b2.seg.bytes > 2*c.maxBytes && c.b2.seg.len() > 0 {
+		c.b2.removeOldest()
+	}
+}
+
+// RemoveOldest T1 超过 p 或 T2 为空时淘汰 T1，否则淘汰 T2
+func (c *ARC) RemoveOldest() {
+	if c.t1.len() > 0 && (c.t1.bytes > c.p || c.t2.len() == 0) {
+		c.b1.add(c.evict(c.t1.oldest(), lru.EvictCapacity))
+		return
+	}
+	if ele := c.t2.oldest(); ele != nil {
+		c.b2.add(c.evict(ele, lru.EvictCapacity))
+	}
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
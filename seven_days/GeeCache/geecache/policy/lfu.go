@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"GeeCache/geecache/lru"
+	"container/heap"
+	"time"
+)
+
+/*
+
+   LFU（最不经常使用）：
+   1、每个条目记录访问次数 freq，用小顶堆按 freq 排序，堆顶就是要淘汰的条目。
+   2、freq 相同时淘汰最久没有访问的条目，用 tick 记录最近一次访问的序号。
+   3、Get 和更新都会增加 freq，新增的条目 freq 为 1。
+
+*/
+
+type LFU struct {
+	base
+	items map[string]*entry
+	heap  lfuHeap
+	tick  uint64
+}
+
+func NewLFU(opts Options) Policy {
+	return &LFU{base: newBase(opts), items: make(map[string]*entry)}
+}
+
+func (c *LFU) touch(e *entry) {
+	c.tick++
+	e.freq++
+	e.tick = c.tick
+	heap.Fix(&c.heap, e.index)
+}
+
+func (c *LFU) Get(key string) (lru.Value, bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(c.clock.Now()) {
+		c.removeEntry(e, lru.EvictExpired)
+		return nil, false
+	}
+	c.touch(e)
+	return e.value, true
+}
+
+func (c *LFU) Peek(key string) (lru.Value, bool) {
+	if e, ok := c.items[key]; ok && !e.expired(c.clock.Now()) {
+		return e.value, true
+	}
+	return nil, false
+}
+
+func (c *LFU) AddWithTTL(key string, value lru.Value, ttl time.Duration) {
+	if e, ok := c.items[key]; ok {
+		c.update(e, value, c.deadline(ttl))
+		c.touch(e)
+	} else {
+		c.tick++
+		e = newEntry(key, value, c.deadline(ttl))
+		e.freq, e.tick = 1, c.tick
+		heap.Push(&c.heap, e)
+		c.items[key] = e
+		c.nbytes += e.bytes
+	}
+	for c.overflow() {
+		c.RemoveOldest()
+	}
+}
+
+func (c *LFU) RemoveOldest() {
+	if len(c.heap) > 0 {
+		c.removeEntry(c.heap[0], lru.EvictCapacity)
+	}
+}
+
+func (c *LFU) Remove(key string) bool {
+	if e, ok := c.items[key]; ok {
+		c.removeEntry(e, lru.EvictRemoved)
+		return true
+	}
+	return false
+}
+
+func (c *LFU) RemoveExpired() int {
+	now := c.clock.Now()
+	n := 0
+	for _, e := range c.items {
+		if e.expired(now) {
+			c.removeEntry(e, lru.EvictExpired)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *LFU) removeEntry(e *entry, reason lru.EvictReason) {
+	heap.Remove(&c.heap, e.index)
+	delete(c.items, e.key)
+	c.evicted(e, reason)
+}
+
+func (c *LFU) Expires(key string) (time.Time, bool) {
+	if e, ok := c.items[key]; ok {
+		return e.expires, true
+	}
+	return time.Time{}, false
+}
+
+func (c *LFU) Len() int {
+	return len(c.items)
+}
+
+// lfuHeap 实现 heap.Interface，按 freq、tick 从小到大排列
+type lfuHeap []*entry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
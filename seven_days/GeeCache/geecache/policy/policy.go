@@ -0,0 +1,279 @@
+package policy
+
+import (
+	"GeeCache/geecache/lru"
+	"container/list"
+	"time"
+)
+
+/*
+
+   1、Policy 抽象了 geecache 中 cache 使用的淘汰策略，lru.Cache 是默认的实现。
+   2、各个策略都按字节数计算容量，条目可以带有过期时间，过期的条目在 Get 时惰性删除，RemoveExpired 主动回收。
+   3、RemoveOldest 由 Group 在 mainCache 和 hotCache 之间平衡容量时调用，淘汰哪个条目由策略决定。
+   4、实现不需要并发安全，由 geecache 的 cache 加锁；Peek 只读，可以在读锁下并发调用。
+
+*/
+
+// Policy 缓存淘汰策略
+type Policy interface {
+	Get(key string) (value lru.Value, ok bool)
+	Peek(key string) (value lru.Value, ok bool) // 不记录访问，过期的条目按未命中处理
+	AddWithTTL(key string, value lru.Value, ttl time.Duration)
+	Remove(key string) bool
+	RemoveOldest()
+	RemoveExpired() int
+	Expires(key string) (time.Time, bool)
+	Len() int
+	Bytes() int64
+}
+
+// Options 创建策略的参数
+type Options struct {
+	MaxBytes  int64 // 0 表示不限制
+	OnEvicted func(key string, value lru.Value, reason lru.EvictReason)
+	Clock     lru.Clock // 为 nil 时使用 lru.SystemClock
+}
+
+// Factory 创建策略，NewLRU、NewLFU、NewTwoQueue、NewARC、NewTinyLFU 都是 Factory
+type Factory func(opts Options) Policy
+
+// NewLRU 最近最少使用，即 lru.Cache
+func NewLRU(opts Options) Policy {
+	c := lru.New(opts.MaxBytes, opts.OnEvicted)
+	if opts.Clock != nil {
+		c.Clock = opts.Clock
+	}
+	return c
+}
+
+var _ Policy = (*lru.Cache)(nil)
+
+// entry 各策略共用的条目，ghost 条目的 value 为 nil，只保留 key 和大小
+type entry struct {
+	key     string
+	value   lru.Value
+	bytes   int64
+	expires time.Time // 零值表示不过期
+	seg     *segment  // 条目所在的队列
+	freq    int       // LFU 访问次数
+	tick    uint64    // LFU 最近访问的序号
+	index   int       // LFU 在堆中的下标
+}
+
+func newEntry(key string, value lru.Value, expires time.Time) *entry {
+	return &entry{key: key, value: value, bytes: int64(len(key)) + int64(value.Len()), expires: expires}
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// base 字节统计、过期时间和淘汰回调
+type base struct {
+	maxBytes  int64
+	nbytes    int64
+	onEvicted func(key string, value lru.Value, reason lru.EvictReason)
+	clock     lru.Clock
+}
+
+func newBase(opts Options) base {
+	b := base{maxBytes: opts.MaxBytes, onEvicted: opts.OnEvicted, clock: opts.Clock}
+	if b.clock == nil {
+		b.clock = lru.SystemClock
+	}
+	return b
+}
+
+func (b *base) deadline(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return b.clock.Now().Add(ttl)
+}
+
+func (b *base) overflow() bool {
+	return b.maxBytes > 0 && b.nbytes > b.maxBytes
+}
+
+// update 替换条目的值，返回大小的变化
+func (b *base) update(e *entry, value lru.Value, expires time.Time) int64 {
+	delta := int64(value.Len()) - int64(e.value.Len())
+	e.value = value
+	e.bytes += delta
+	e.expires = expires
+	b.nbytes += delta
+	return delta
+}
+
+func (b *base) evicted(e *entry, reason lru.EvictReason) {
+	b.nbytes -= e.bytes
+	if b.onEvicted != nil {
+		b.onEvicted(e.key, e.value, reason)
+	}
+}
+
+func (b *base) Bytes() int64 {
+	return b.nbytes
+}
+
+// segment 按访问顺序排列的一段队列，front 为最近访问
+type segment struct {
+	ll    *list.List
+	bytes int64
+}
+
+func newSegment() *segment {
+	return &segment{ll: list.New()}
+}
+
+func (s *segment) pushFront(e *entry) *list.Element {
+	e.seg = s
+	s.bytes += e.bytes
+	return s.ll.PushFront(e)
+}
+
+func (s *segment) remove(ele *list.Element) *entry {
+	e := ele.Value.(*entry)
+	s.bytes -= e.bytes
+	s.ll.Remove(ele)
+	e.seg = nil
+	return e
+}
+
+// oldest 返回最久未访问的条目，队列为空时返回 nil
+func (s *segment) oldest() *list.Element {
+	return s.ll.Back()
+}
+
+func (s *segment) len() int {
+	return s.ll.Len()
+}
+
+// segmented 基于 segment 的策略（2Q、ARC、W-TinyLFU）共用的部分
+type segmented struct {
+	base
+	items map[string]*list.Element
+}
+
+func newSegmented(opts Options) segmented {
+	return segmented{base: newBase(opts), items: make(map[string]*list.Element)}
+}
+
+func (s *segmented) Peek(key string) (lru.Value, bool) {
+	if ele, ok := s.items[key]; ok {
+		if e := ele.Value.(*entry); !e.expired(s.clock.Now()) {
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// lookup 返回未过期的条目，过期的条目被删除
+func (s *segmented) lookup(key string) (*list.Element, bool) {
+	ele, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	if ele.Value.(*entry).expired(s.clock.Now()) {
+		s.evict(ele, lru.EvictExpired)
+		return nil, false
+	}
+	return ele, true
+}
+
+func (s *segmented) insert(seg *segment, e *entry) {
+	s.items[e.key] = seg.pushFront(e)
+	s.nbytes += e.bytes
+}
+
+// move 把条目移动到 seg 的最前面
+func (s *segmented) move(ele *list.Element, seg *segment) *list.Element {
+	e := ele.Value.(*entry)
+	e.seg.remove(ele)
+	ele = seg.pushFront(e)
+	s.items[e.key] = ele
+	return ele
+}
+
+func (s *segmented) evict(ele *list.Element, reason lru.EvictReason) *entry {
+	e := ele.Value.(*entry)
+	e.seg.remove(ele)
+	delete(s.items, e.key)
+	s.evicted(e, reason)
+	return e
+}
+
+// updateIn 替换条目的值，同时更新所在 segment 的大小
+func (s *segmented) updateIn(ele *list.Element, value lru.Value, expires time.Time) {
+	e := ele.Value.(*entry)
+	e.seg.bytes += s.update(e, value, expires)
+}
+
+func (s *segmented) Remove(key string) bool {
+	if ele, ok := s.items[key]; ok {
+		s.evict(ele, lru.EvictRemoved)
+		return true
+	}
+	return false
+}
+
+func (s *segmented) RemoveExpired() int {
+	now := s.clock.Now()
+	n := 0
+	for _, ele := range s.items {
+		if ele.Value.(*entry).expired(now) {
+			s.evict(ele, lru.EvictExpired)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *segmented) Expires(key string) (time.Time, bool) {
+	if ele, ok := s.items[key]; ok {
+		return ele.Value.(*entry).expires, true
+	}
+	return time.Time{}, false
+}
+
+func (s *segmented) Len() int {
+	return len(s.items)
+}
+
+// ghosts 只记录被淘汰的 key 和大小，用于 2Q 和 ARC 判断 key 是否刚被淘汰过
+type ghosts struct {
+	seg   *segment
+	items map[string]*list.Element
+}
+
+func newGhosts() *ghosts {
+	return &ghosts{seg: newSegment(), items: make(map[string]*list.Element)}
+}
+
+func (g *ghosts) add(e *entry) {
+	if ele, ok := g.items[e.key]; ok {
+		g.seg.remove(ele)
+	}
+	g.items[e.key] = g.seg.pushFront(&entry{key: e.key, bytes: e.bytes})
+}
+
+func (g *ghosts) take(key string) bool {
+	ele, ok := g.items[key]
+	if ok {
+		g.seg.remove(ele)
+		delete(g.items, key)
+	}
+	return ok
+}
+
+func (g *ghosts) has(key string) bool {
+	_, ok := g.items[key]
+	return ok
+}
+
+func (g *ghosts) removeOldest() {
+	if ele := g.seg.oldest(); ele != nil {
+		delete(g.items, g.seg.remove(ele).key)
+	}
+}
@@ -0,0 +1,219 @@
+package policy
+
+import (
+	"GeeCache/geecache/lru"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+var factories = []struct {
+	name string
+	new  Factory
+}{
+	{"LRU", NewLRU},
+	{"LFU", NewLFU},
+	{"2Q", NewTwoQueue},
+	{"ARC", NewARC},
+	{"TinyLFU", NewTinyLFU},
+}
+
+// 所有策略都要满足的基本语义：命中、容量、字节统计、删除和过期
+func TestPolicies(t *testing.T) {
+	for _, f := range factories {
+		t.Run(f.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			reasons := make(map[lru.EvictReason]int)
+			p := f.new(Options{
+				MaxBytes:  100,
+				Clock:     clock,
+				OnEvicted: func(key string, value lru.Value, reason lru.EvictReason) { reasons[reason]++ },
+			})
+			p.AddWithTTL("k1", String("v1"), 0)
+			if v, ok := p.Get("k1"); !ok || v.(String) != "v1" {
+				t.Fatalf("get k1 failed")
+			}
+			if _, ok := p.Get("k2"); ok {
+				t.Fatalf("get k2 should miss")
+			}
+			p.AddWithTTL("k1", String("v1v1"), 0)
+			if p.Len() != 1 || p.Bytes() != 6 {
+				t.Fatalf("len=%d bytes=%d, want 1 and 6", p.Len(), p.Bytes())
+			}
+
+			for i := 0; i < 50; i++ {
+				p.AddWithTTL(fmt.Sprintf("key%02d", i), String("v"), 0)
+				if p.Bytes() > 100 {
+					t.Fatalf("bytes %d exceeds capacity", p.Bytes())
+				}
+			}
+			if reasons[lru.EvictCapacity] == 0 {
+				t.Fatalf("expect capacity evictions")
+			}
+
+			p.AddWithTTL("ttl", String("v"), time.Second)
+			if expires, ok := p.Expires("ttl"); ok && !expires.Equal(clock.now.Add(time.Second)) {
+				t.Fatalf("expires = %v", expires)
+			}
+			clock.now = clock.now.Add(2 * time.Second)
+			if _, ok := p.Get("ttl"); ok {
+				t.Fatalf("expired entry should miss")
+			}
+
+			// 清空之后再加入，保证所有策略都会接纳这些 key
+			for p.Len() > 0 {
+				p.RemoveOldest()
+			}
+			p.AddWithTTL("a", String("v"), time.Second)
+			p.AddWithTTL("b", String("v"), time.Second)
+			p.AddWithTTL("keep", String("v"), 0)
+			if p.Len() != 3 {
+				t.Fatalf("len = %d after adding 3 keys", p.Len())
+			}
+			clock.now = clock.now.Add(2 * time.Second)
+			if removed := p.RemoveExpired(); removed != 2 || p.Len() != 1 {
+				t.Fatalf("RemoveExpired removed %d, len %d; want 2 and 1", removed, p.Len())
+			}
+			if _, ok := p.Peek("keep"); !ok {
+				t.Fatalf("RemoveExpired removed an entry without TTL")
+			}
+
+			p.AddWithTTL("c", String("v"), 0)
+			if !p.Remove("c") {
+				t.Fatalf("first remove should succeed")
+			}
+			if p.Remove("c") {
+				t.Fatalf("second remove should fail")
+			}
+			for p.Len() > 0 {
+				p.RemoveOldest()
+			}
+			if p.Bytes() != 0 {
+				t.Fatalf("bytes = %d after removing all", p.Bytes())
+			}
+		})
+	}
+}
+
+// 2Q：只访问过一次的 key 在 A1in 中先被淘汰，A1out 中的 key 再次加入时进入 Am
+func TestTwoQueue(t *testing.T) {
+	p := NewTwoQueue(Options{MaxBytes: 40})
+	p.AddWithTTL("hot1", String("vvvv"), 0) // 8 字节
+	p.RemoveOldest()                        // hot1 进入 A1out
+	p.AddWithTTL("hot1", String("vvvv"), 0) // 进入 Am
+	for i := 0; i < 10; i++ {
+		p.AddWithTTL(fmt.Sprintf("cold%d", i), String("v"), 0)
+	}
+	if _, ok := p.Get("hot1"); !ok {
+		t.Fatalf("hot1 should stay in Am while cold keys are scanned")
+	}
+}
+
+// ARC：扫描不会把访问过多次的条目挤出去
+func TestARC(t *testing.T) {
+	p := NewARC(Options{MaxBytes: 40})
+	p.AddWithTTL("hot1", String("vvvv"), 0)
+	p.Get("hot1") // 进入 T2
+	for i := 0; i < 10; i++ {
+		p.AddWithTTL(fmt.Sprintf("cold%d", i), String("v"), 0)
+	}
+	if _, ok := p.Get("hot1"); !ok {
+		t.Fatalf("hot1 should stay in T2 while cold keys are scanned")
+	}
+}
+
+// TinyLFU：main 满了以后，访问频率低的新 key 不会被接纳
+func TestTinyLFUAdmission(t *testing.T) {
+	p := NewTinyLFU(Options{MaxBytes: 100})
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("hot%d", i)
+		for j := 0; j < 5; j++ {
+			p.Get(key)
+		}
+		p.AddWithTTL(key, String("vvvvv"), 0) // 9 字节
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("one%d", i)
+		p.Get(key)
+		p.AddWithTTL(key, String("vvvvv"), 0)
+	}
+	for i := 0; i < 10; i++ {
+		if _, ok := p.Get(fmt.Sprintf("hot%d", i)); !ok {
+			t.Fatalf("hot%d should not be evicted by one-hit keys", i)
+		}
+	}
+}
+
+func TestCMSketch(t *testing.T) {
+	s := newCMSketch(64)
+	h := hashKey("a")
+	for i := 0; i < 20; i++ {
+		s.increment(h)
+	}
+	if got := s.estimate(h); got != sketchMaxFreq {
+		t.Fatalf("estimate = %d, want %d", got, sketchMaxFreq)
+	}
+	s.reset()
+	if got := s.estimate(h); got != sketchMaxFreq/2 {
+		t.Fatalf("estimate after reset = %d, want %d", got, sketchMaxFreq/2)
+	}
+}
+
+/*
+
+   按 Zipf 分布生成访问序列，比较各个策略的命中率：
+   go test -bench Zipf -run none ./geecache/policy
+   命中率通过 b.ReportMetric 以 hit% 输出，未命中时模拟回源后加入缓存。
+
+*/
+
+const zipfKeys = 100000
+
+func zipfTrace(s float64, n int) []string {
+	r := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(r, s, 1, zipfKeys-1)
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = fmt.Sprintf("key%06d", z.Uint64())
+	}
+	return trace
+}
+
+func BenchmarkZipf(b *testing.B) {
+	value := String("value")
+	entryBytes := int64(len("key000000") + value.Len())
+	for _, s := range []float64{1.01, 1.2} {
+		trace := zipfTrace(s, 200000)
+		for _, size := range []int64{1000, 10000} {
+			for _, f := range factories {
+				name := fmt.Sprintf("s=%.2f/entries=%d/%s", s, size, f.name)
+				b.Run(name, func(b *testing.B) {
+					var hits, total int
+					for i := 0; i < b.N; i++ {
+						p := f.new(Options{MaxBytes: size * entryBytes})
+						for _, key := range trace {
+							if _, ok := p.Get(key); ok {
+								hits++
+							} else {
+								p.AddWithTTL(key, value, 0)
+							}
+						}
+						total += len(trace)
+					}
+					b.ReportMetric(100*float64(hits)/float64(total), "hit%")
+				})
+			}
+		}
+	}
+}
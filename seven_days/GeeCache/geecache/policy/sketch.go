@@ -0,0 +1,82 @@
+package policy
+
+/*
+
+   count-min sketch，用很少的内存估计 key 的访问频率：
+   1、depth 行计数器，每行用不同的 hash 选一个计数器加一，估计值取各行的最小值。
+   2、计数器最大为 15（和 Caffeine 一样只需要 4 bit），避免热点 key 的计数无限增长。
+   3、累计增加次数达到 10 倍宽度时所有计数器减半，让频率随时间衰减，旧的热点可以被淘汰。
+
+*/
+
+const (
+	sketchDepth   = 4
+	sketchMaxFreq = 15
+)
+
+type cmSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// newCMSketch width 会向上取整为 2 的幂
+func newCMSketch(width int) *cmSketch {
+	n := 1
+	for n < width {
+		n <<= 1
+	}
+	s := &cmSketch{mask: uint64(n - 1), resetAt: 10 * n}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, n)
+	}
+	return s
+}
+
+// index 用 h 的高低 32 位做双重 hash，得到第 i 行的下标
+func (s *cmSketch) index(h uint64, i int) uint64 {
+	h1, h2 := h&0xffffffff, h>>32|1
+	return (h1 + uint64(i)*h2) & s.mask
+}
+
+func (s *cmSketch) increment(h uint64) {
+	for i := range s.rows {
+		if idx := s.index(h, i); s.rows[i][idx] < sketchMaxFreq {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) estimate(h uint64) uint8 {
+	min := uint8(sketchMaxFreq)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(h, i)]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// hashKey FNV-1a，不分配内存
+func hashKey(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"GeeCache/geecache/lru"
+	"container/list"
+	"time"
+)
+
+/*
+
+   W-TinyLFU（Einziger & Friedman，Caffeine 使用的策略）：
+   1、新条目先进入占容量 1% 的 window LRU，用来吸收突发的新 key。
+   2、window 放不下时，最旧的条目作为候选者进入 main，main 是分段 LRU：probation 占 20%，protected 占 80%。
+   3、main 放不下时，用 count-min sketch 比较候选者和 probation 中最旧条目（victim）的访问频率，
+      候选者更频繁才淘汰 victim 接纳候选者，否则直接淘汰候选者。
+   4、probation 中的条目再次被访问时晋升到 protected，protected 超出大小时最旧的条目降级回 probation。
+   5、每次 Get（包括未命中）都会在 sketch 中记录一次访问。
+
+*/
+
+type TinyLFU struct {
+	segmented
+	sketch                       *cmSketch
+	window, probation, protected *segment
+	windowMax, protectedMax      int64
+}
+
+func NewTinyLFU(opts Options) Policy {
+	c := &TinyLFU{
+		segmented: newSegmented(opts),
+		window:    newSegment(),
+		probation: newSegment(),
+		protected: newSegment(),
+	}
+	c.windowMax = c.maxBytes / 100
+	if c.windowMax == 0 && c.maxBytes > 0 {
+		c.windowMax = 1
+	}
+	c.protectedMax = (c.maxBytes - c.windowMax) * 8 / 10
+	// 按平均每个条目 16 字节估计条目数，决定 sketch 的宽度
+	width := c.maxBytes / 16
+	if width < 64 {
+		width = 64
+	} else if width > 1<<22 {
+		width = 1 << 22
+	}
+	c.sketch = newCMSketch(int(width))
+	return c
+}
+
+func (c *TinyLFU) Get(key string) (lru.Value, bool) {
+	c.sketch.increment(hashKey(key))
+	ele, ok := c.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	c.access(ele)
+	return ele.Value.(*entry).value, true
+}
+
+// access 命中后调整条目的位置
+func (c *TinyLFU) access(ele *list.Element) {
+	switch ele.Value.(*entry).seg {
+	case c.window:
+		c.window.ll.MoveToFront(ele)
+	case c.protected:
+		c.protected.ll.MoveToFront(ele)
+	case c.probation:
+		c.move(ele, c.protected)
+		for c.maxBytes > 0 && c.protected.bytes > c.protectedMax {
+			c.move(c.protected.oldest(), c.probation)
+		}
+	}
+}
+
+func (c *TinyLFU) AddWithTTL(key string, value lru.Value, ttl time.Duration) {
+	if ele, ok := c.items[key]; ok {
+		c.updateIn(ele, value, c.deadline(ttl))
+		c.access(ele)
+	} else {
+		c.insert(c.window, newEntry(key, value, c.deadline(ttl)))
+	}
+	for c.window.bytes > c.windowMax && c.window.len() > 0 {
+		c.admit(c.move(c.window.oldest(), c.probation))
+	}
+	for c.overflow() {
+		c.RemoveOldest()
+	}
+}
+
+// admit 候选者已经在 probation 的最前面，main 放不下时和 victim 比较频率
+func (c *TinyLFU) admit(candidate *list.Element) {
+	if c.maxBytes <= 0 {
+		return
+	}
+	mainMax := c.maxBytes - c.windowMax
+	cand := candidate.Value.(*entry)
+	for c.probation.bytes+c.protected.bytes > mainMax {
+		victim := c.probation.oldest()
+		if victim == candidate {
+			victim = c.protected.oldest()
+		}
+		if victim == nil || victim == candidate {
+			c.evict(candidate, lru.EvictCapacity)
+			return
+		}
+		if c.sketch.estimate(hashKey(cand.key)) > c.sketch.estimate(hashKey(victim.Value.(*entry).key)) {
+			c.evict(victim, lru.EvictCapacity)
+		} else {
+			c.evict(candidate, lru.EvictCapacity)
+			return
+		}
+	}
+}
+
+// RemoveOldest 依次从 probation、window、protected 中淘汰最旧的条目
+func (c *TinyLFU) RemoveOldest() {
+	for _, seg := range []*segment{c.probation, c.window, c.protected} {
+		if ele := seg.oldest(); ele != nil {
+			c.evict(ele, lru.EvictCapacity)
+			return
+		}
+	}
+}
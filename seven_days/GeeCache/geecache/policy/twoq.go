@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"GeeCache/geecache/lru"
+	"time"
+)
+
+/*
+
+   2Q（Johnson & Shasha）：
+   1、新条目先进入 FIFO 队列 A1in，只访问过一次的条目不会挤掉经常访问的条目。
+   2、A1in 超过容量的 1/4 时从队首淘汰，淘汰的 key 记录在 ghost 队列 A1out 中（不保存值，最多为容量的 1/2）。
+   3、再次加入的 key 如果在 A1out 中，说明它不只被访问了一次，直接进入 LRU 队列 Am。
+   4、A1in 中的条目被访问时不调整位置，Am 中的条目按 LRU 调整。
+
+*/
+
+type TwoQueue struct {
+	segmented
+	in  *segment // A1in
+	am  *segment // Am
+	out *ghosts  // A1out
+}
+
+func NewTwoQueue(opts Options) Policy {
+	return &TwoQueue{
+		segmented: newSegmented(opts),
+		in:        newSegment(),
+		am:        newSegment(),
+		out:       newGhosts(),
+	}
+}
+
+func (c *TwoQueue) Get(key string) (lru.Value, bool) {
+	ele, ok := c.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	if ele.Value.(*entry).seg == c.am {
+		c.am.ll.MoveToFront(ele)
+	}
+	return ele.Value.(*entry).value, true
+}
+
+func (c *TwoQueue) AddWithTTL(key string, value lru.Value, ttl time.Duration) {
+	if ele, ok := c.items[key]; ok {
+		c.updateIn(ele, value, c.deadline(ttl))
+		if ele.Value.(*entry).seg == c.am {
+			c.am.ll.MoveToFront(ele)
+		}
+	} else if c.out.take(key) {
+		c.insert(c.am, newEntry(key, value, c.deadline(ttl)))
+	} else {
+		c.insert(c.in, newEntry(key, value, c.deadline(ttl)))
+	}
+	for c.overflow() {
+		c.RemoveOldest()
+	}
+}
+
+// RemoveOldest A1in 超过 1/4 或 Am 为空时淘汰 A1in，否则淘汰 Am
+func (c *TwoQueue) RemoveOldest() {
+	if c.in.len() > 0 && (c.in.bytes > c.maxBytes/4 || c.am.len() == 0) {
+		e := c.evict(c.in.oldest(), lru.EvictCapacity)
+		c.out.add(e)
+		for c.out.seg.bytes > c.maxBytes/2 && c.out.seg.len() > 0 {
+			c.out.removeOldest()
+		}
+		return
+	}
+	if ele := c.am.oldest(); ele != nil {
+		c.evict(ele, lru.EvictCapacity)
+	}
+}
@@ -0,0 +1,252 @@
+package geecache
+
+import (
+	"GeeCache/geecache/consistenthash"
+	pb "GeeCache/geecache/geecachepb"
+	geerpc "GeeRPC"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const defaultRPCTimeout = time.Second
+
+/*
+
+   RPCPool 是基于 GeeRPC 的 PeerPicker，与 HTTPPool 相比，节点之间复用长连接，不再为每次未命中发起一次 HTTP 请求。
+   1、服务端：NewRPCPool 在内部的 geerpc.Server 上注册 GroupCache 服务，对应 geecachepb 中的 GroupCache 的 Get/Set/Remove。
+   2、客户端：每个 peer 对应一个 rpcGetter，复用同一个 geerpc.Client，连接断开后在下一次调用时重新建立。
+   3、每次调用都带有 Timeout 的截止时间，超时后返回错误，由 Group 回退到本地加载。
+   节点地址使用 XDial 的格式，例如 tcp@10.0.0.1:9999。
+
+*/
+
+// GroupCache 是注册到 geerpc.Server 上的服务，服务方法为 GroupCache.Get
+type GroupCache struct{}
+
+// Get 实现 geecachepb 中的 GroupCache.Get，错误通过 Response.Code 返回
+func (GroupCache) Get(req *pb.Request, res *pb.Response) error {
+	group := GetGroup(req.Group)
+	if group == nil {
+		res.Code = pb.Code_NO_SUCH_GROUP
+		res.Message = "no such group: " + req.Group
+		return nil
+	}
+	view, err := group.Get(req.Key)
+	if IsNotFound(err) {
+		res.Code = pb.Code_NOT_FOUND
+		res.Message = err.Error()
+		return nil
+	}
+	if err != nil {
+		res.Code = pb.Code_INTERNAL
+		res.Message = err.Error()
+		return nil
+	}
+	res.Value = view.ByteSlice()
+	res.TtlMs = ttlToMs(group.ttlOf(req.Key))
+	return nil
+}
+
+// Set 实现 GroupCache.Set，写入本节点的 mainCache
+func (GroupCache) Set(req *pb.SetRequest, res *pb.Response) error {
+	group := GetGroup(req.Group)
+	if group == nil {
+		res.Code = pb.Code_NO_SUCH_GROUP
+		res.Message = "no such group: " + req.Group
+		return nil
+	}
+	group.setLocally(req.Key, ByteView{b: req.Value}, msToTTL(req.TtlMs))
+	return nil
+}
+
+// Remove 实现 GroupCache.Remove，从本节点的 mainCache 和 hotCache 中删除
+func (GroupCache) Remove(req *pb.Request, res *pb.Response) error {
+	group := GetGroup(req.Group)
+	if group == nil {
+		res.Code = pb.Code_NO_SUCH_GROUP
+		res.Message = "no such group: " + req.Group
+		return nil
+	}
+	group.removeLocally(req.Key)
+	return nil
+}
+
+// RPCPool 为一个 GeeRPC 对等体池实现了 PeerPicker。
+type RPCPool struct {
+	self      string
+	server    *geerpc.Server
+	opt       *geerpc.Option
+	Timeout   time.Duration // 每次调用的截止时间，默认 1s
+	mu        sync.Mutex
+	peers     *consistenthash.Map
+	rpcGetter map[string]*rpcGetter
+}
+
+// NewRPCPool 创建 RPCPool，self 形如 tcp@10.0.0.1:9999，opt 为 nil 时使用 geerpc.DefaultOption
+func NewRPCPool(self string, opt *geerpc.Option) *RPCPool {
+	server := geerpc.NewServer()
+	if err := server.Register(GroupCache{}); err != nil {
+		panic(err)
+	}
+	return &RPCPool{
+		self:    self,
+		server:  server,
+		opt:     opt,
+		Timeout: defaultRPCTimeout,
+	}
+}
+
+// Log info with server name
+func (p *RPCPool) Log(format string, v ...interface{}) {
+	log.Printf("[RPC Server %s] %s", p.self, fmt.Sprintf(format, v...))
+}
+
+// Serve 在 lis 上处理其他节点的请求，直到 lis 被关闭
+func (p *RPCPool) Serve(lis net.Listener) {
+	p.Log("serving on %s", lis.Addr())
+	p.server.Accept(lis)
+}
+
+// Server 返回内部的 geerpc.Server，可以用来注册其他服务或通过 HTTP 提供服务
+func (p *RPCPool) Server() *geerpc.Server {
+	return p.server
+}
+
+// Set 一致性 hash 实例，将节点加入环中，已有节点的连接会被复用
+func (p *RPCPool) Set(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.peers = consistenthash.New(defaultReplicas, nil)
+	p.peers.Add(peers...)
+	getters := make(map[string]*rpcGetter, len(peers))
+	for _, peer := range peers {
+		if g, ok := p.rpcGetter[peer]; ok {
+			getters[peer] = g
+			continue
+		}
+		getters[peer] = &rpcGetter{addr: peer, pool: p}
+	}
+	for peer, g := range p.rpcGetter {
+		if _, ok := getters[peer]; !ok {
+			g.close()
+		}
+	}
+	p.rpcGetter = getters
+}
+
+// PickPeer 根据 key 选择一个 peer （节点）
+func (p *RPCPool) PickPeer(key string) (PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil, false
+	}
+	if peer := p.peers.Get(key); peer != "" && peer != p.self {
+		p.Log("Pick peer %s", peer)
+		return p.rpcGetter[peer], true
+	}
+	return nil, false
+}
+
+// GetAll 返回除自己以外的所有节点
+func (p *RPCPool) GetAll() []PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	peers := make([]PeerGetter, 0, len(p.rpcGetter))
+	for peer, getter := range p.rpcGetter {
+		if peer != p.self {
+			peers = append(peers, getter)
+		}
+	}
+	return peers
+}
+
+// Close 关闭所有到其他节点的连接
+func (p *RPCPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, g := range p.rpcGetter {
+		g.close()
+	}
+	return nil
+}
+
+var _ PeerPicker = (*RPCPool)(nil)
+
+/********************************Clinet*************************************/
+type rpcGetter struct {
+	addr   string // 例如 tcp@10.0.0.2:9999
+	pool   *RPCPool
+	mu     sync.Mutex
+	client *geerpc.Client
+}
+
+// dial 返回可用的连接，没有时重新建立
+func (r *rpcGetter) dial() (*geerpc.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client != nil && r.client.IsAvailable() {
+		return r.client, nil
+	}
+	if r.client != nil {
+		r.client.Close()
+		r.client = nil
+	}
+	opt := r.pool.opt
+	if opt != nil {
+		copied := *opt
+		opt = &copied
+	}
+	client, err := geerpc.XDial(r.addr, opt)
+	if err != nil {
+		return nil, err
+	}
+	r.client = client
+	return client, nil
+}
+
+func (r *rpcGetter) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client != nil {
+		r.client.Close()
+		r.client = nil
+	}
+}
+
+func (r *rpcGetter) Get(in *pb.Request, out *pb.Response) error {
+	return r.call("GroupCache.Get", in, out)
+}
+
+func (r *rpcGetter) Set(in *pb.SetRequest, out *pb.Response) error {
+	return r.call("GroupCache.Set", in, out)
+}
+
+func (r *rpcGetter) Remove(in *pb.Request, out *pb.Response) error {
+	return r.call("GroupCache.Remove", in, out)
+}
+
+// call 带截止时间调用 serviceMethod，Response.Code 不为 OK 时返回错误
+func (r *rpcGetter) call(serviceMethod string, in interface{}, out *pb.Response) error {
+	client, err := r.dial()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if r.pool.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.pool.Timeout)
+		defer cancel()
+	}
+	if err := client.Call(ctx, serviceMethod, in, out); err != nil {
+		return err
+	}
+	return responseError(out)
+}
+
+var _ PeerGetter = (*rpcGetter)(nil)
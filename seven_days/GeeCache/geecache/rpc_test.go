@@ -0,0 +1,84 @@
+package geecache
+
+import (
+	pb "GeeCache/geecache/geecachepb"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRPCPool(t *testing.T) {
+	slow := make(chan struct{})
+	g := NewGroup("scores-rpc", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if key == "slow" {
+			<-slow
+		}
+		if key == "missing" {
+			return nil, &NotFoundError{Key: key}
+		}
+		return []byte(db[key]), nil
+	}))
+	g.SetTTL(time.Minute)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	addr := "tcp@" + lis.Addr().String()
+	server := NewRPCPool(addr, nil)
+	go server.Serve(lis)
+
+	pool := NewRPCPool("tcp@127.0.0.1:0", nil)
+	pool.Timeout = 100 * time.Millisecond
+	pool.Set(addr)
+	defer pool.Close()
+	peer, ok := pool.PickPeer("Tom")
+	if !ok {
+		t.Fatal("expect remote peer for Tom")
+	}
+
+	for i := 0; i < 2; i++ {
+		res := &pb.Response{}
+		if err := peer.Get(&pb.Request{Group: "scores-rpc", Key: "Tom"}, res); err != nil {
+			t.Fatal(err)
+		}
+		if string(res.Value) != db["Tom"] || res.TtlMs <= 0 {
+			t.Fatalf("unexpected response: %v", res)
+		}
+	}
+	first := peer.(*rpcGetter).client
+
+	res := &pb.Response{}
+	if err := peer.Get(&pb.Request{Group: "unknown", Key: "Tom"}, res); err == nil || IsNotFound(err) || res.Code != pb.Code_NO_SUCH_GROUP {
+		t.Fatalf("expect NO_SUCH_GROUP for unknown group, got %v %v", err, res.Code)
+	}
+	if err := peer.Get(&pb.Request{Group: "scores-rpc", Key: "missing"}, res); !IsNotFound(err) || res.Code != pb.Code_NOT_FOUND {
+		t.Fatalf("expect NOT_FOUND for missing key, got %v %v", err, res.Code)
+	}
+
+	// 超过截止时间的调用返回错误，连接仍然可以复用
+	err = peer.Get(&pb.Request{Group: "scores-rpc", Key: "slow"}, &pb.Response{})
+	close(slow)
+	if err == nil || !strings.Contains(err.Error(), "deadline") {
+		t.Fatalf("expect deadline error, got %v", err)
+	}
+	if err := peer.Get(&pb.Request{Group: "scores-rpc", Key: "Jack"}, &pb.Response{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.Set(&pb.SetRequest{Group: "scores-rpc", Key: "Tom", Value: []byte("700")}, &pb.Response{}); err != nil {
+		t.Fatal(err)
+	}
+	if view, _ := g.Get("Tom"); view.String() != "700" {
+		t.Fatalf("GroupCache.Set should update Tom, got %s", view)
+	}
+	if err := peer.Remove(&pb.Request{Group: "scores-rpc", Key: "Tom"}, &pb.Response{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.mainCache.get("Tom"); ok {
+		t.Fatalf("GroupCache.Remove should remove Tom")
+	}
+	if peer.(*rpcGetter).client != first {
+		t.Fatal("expect connection to be reused")
+	}
+}
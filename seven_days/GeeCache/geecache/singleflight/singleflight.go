@@ -1,14 +1,59 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
 
+/*
+
+   1、针对相同的 key，同一时间只有一个 fn 在执行，其他调用者等待并共用它的结果，Result.Shared 表示结果是否被多个调用者共用。
+   2、fn 在单独的 goroutine 中执行，Do 的调用者可以通过 ctx 放弃等待，fn 不受影响，其他调用者仍然可以拿到结果。
+   3、fn panic 时，所有通过 Do 等待的调用者都会以同一个 *PanicError panic；fn 调用 runtime.Goexit 时，调用者也会 Goexit。
+      DoChan 无法在调用者的 goroutine 中 panic，改为在 Result.Err 中返回 *PanicError 或 ErrGoexit。
+   4、Forget 让之后的调用重新执行 fn，不再等待正在执行的那一次。
+
+*/
+
+// ErrGoexit fn 调用了 runtime.Goexit
+var ErrGoexit = errors.New("singleflight: runtime.Goexit was called")
+
+// PanicError fn panic 时的值和堆栈
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("singleflight: panic: %v\n\n%s", p.Value, p.Stack)
+}
+
+// Unwrap panic 的值是 error 时返回它
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// Result DoChan 返回的结果
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool
+}
+
+// call 代表正在进行中，或已经结束的请求。done 在 fn 返回后关闭。
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done  chan struct{}
+	val   interface{}
+	err   error
+	dups  int             // 共用结果的其他调用者个数
+	chans []chan<- Result // DoChan 的调用者
 }
 
-// call 代表正在进行中，或已经结束的请求。使用 sync.WaitGroup 锁避免重入。
 // Group 是 singleflight 的主数据结构，管理不同 key 的请求(call)。
 type Group struct {
 	mu sync.Mutex
@@ -17,27 +62,105 @@ type Group struct {
 
 // Do 的作用就是，针对相同的 key，无论 Do 被调用多少次，
 // 函数 fn 都只会被调用一次，等待 fn 调用结束了，返回返回值或错误。
-func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+// ctx 结束时立即返回 ctx.Err()。
+func (g *Group) Do(ctx context.Context, key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
+	c := g.join(key, fn, nil)
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return nil, ctx.Err(), false
+	}
+
 	g.mu.Lock()
-	if g.m == nil {
-		g.m = make(map[string]*call)
+	shared = c.dups > 0
+	g.mu.Unlock()
+	if e, ok := c.err.(*PanicError); ok {
+		panic(e)
 	}
-	if c, ok := g.m[key]; ok {
-		g.mu.Unlock()
-		c.wg.Wait()         // 如果请求正在进行中，则等待
-		return c.val, c.err // 请求结束，返回结果
+	if c.err == ErrGoexit {
+		runtime.Goexit()
 	}
-	c := new(call)
-	c.wg.Add(1)  // 发起请求前加锁
-	g.m[key] = c // 添加到 g.m，表明 key 已经有对应的请求在处理
-	g.mu.Unlock()
+	return c.val, c.err, shared
+}
 
-	c.val, c.err = fn() // 调用 fn，发起请求
-	c.wg.Done()         // 请求结束
+// DoChan 同 Do，结果通过 channel 返回，channel 只会收到一个 Result
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.join(key, fn, ch)
+	return ch
+}
 
+// Forget 忘记 key 正在执行的请求，之后的调用会重新执行 fn
+func (g *Group) Forget(key string) {
 	g.mu.Lock()
-	delete(g.m, key) // 更新 g.m
+	delete(g.m, key)
 	g.mu.Unlock()
+}
+
+// Dups 返回 key 正在进行的请求有多少个其他调用者在等待，没有进行中的请求时返回 0
+func (g *Group) Dups(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.m[key]; ok {
+		return c.dups
+	}
+	return 0
+}
 
-	return c.val, c.err
+// join key 已有请求在处理时加入它，否则创建新的请求并在新的 goroutine 中执行 fn
+func (g *Group) join(key string, fn func() (interface{}, error), ch chan<- Result) *call {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	c, ok := g.m[key]
+	if ok {
+		c.dups++
+	} else {
+		c = &call{done: make(chan struct{})}
+		g.m[key] = c // 添加到 g.m，表明 key 已经有对应的请求在处理
+		go g.doCall(c, key, fn)
+	}
+	if ch != nil {
+		c.chans = append(c.chans, ch)
+	}
+	return c
+}
+
+// doCall 执行 fn，区分正常返回、panic 和 runtime.Goexit
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	normalReturn := false
+	recovered := false
+
+	defer func() {
+		if !normalReturn && !recovered { // recover 不到值，说明 fn 调用了 runtime.Goexit
+			c.err = ErrGoexit
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		close(c.done)
+		if g.m[key] == c { // 可能已经被 Forget，或者被新的请求替换
+			delete(g.m, key)
+		}
+		for _, ch := range c.chans {
+			ch <- Result{Val: c.val, Err: c.err, Shared: c.dups > 0}
+		}
+	}()
+
+	func() {
+		defer func() {
+			if !normalReturn {
+				if r := recover(); r != nil {
+					c.err = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}
+		}()
+		c.val, c.err = fn()
+		normalReturn = true
+	}()
+
+	if !normalReturn {
+		recovered = true
+	}
 }
@@ -0,0 +1,235 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	var g Group
+	v, err, shared := g.Do(context.Background(), "key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil || shared {
+		t.Fatalf("Do = %v, %v, %v; want bar, nil, false", v, err, shared)
+	}
+
+	someErr := errors.New("some error")
+	_, err, _ = g.Do(context.Background(), "key", func() (interface{}, error) {
+		return nil, someErr
+	})
+	if err != someErr {
+		t.Fatalf("Do error = %v; want %v", err, someErr)
+	}
+}
+
+// waitDups 等待 key 正在进行的请求有 n 个其他调用者
+func waitDups(t *testing.T, g *Group, key string, n int) {
+	for deadline := time.Now().Add(time.Second); g.Dups(key) != n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Dups(%q) = %d, want %d", key, g.Dups(key), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 并发的相同 key 只执行一次 fn，所有调用者拿到相同的结果，并且 shared 为 true
+func TestDoDupSuppress(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	const n = 10
+	var wg, started sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			v, err, shared := g.Do(context.Background(), "key", fn)
+			if v != "bar" || err != nil || !shared {
+				t.Errorf("Do = %v, %v, %v; want bar, nil, true", v, err, shared)
+			}
+		}()
+	}
+	started.Wait()
+	waitDups(t, &g, "key", n-1) // 等待所有调用者加入
+	close(release)
+	wg.Wait()
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("fn called %d times, want 1", got)
+	}
+}
+
+// ctx 结束的调用者立即返回，fn 继续执行，其他调用者不受影响
+func TestDoContextCancel(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+
+	result := g.DoChan("key", fn)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err, _ := g.Do(ctx, "key", fn); err != context.DeadlineExceeded {
+		t.Fatalf("Do error = %v; want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if r := <-result; r.Val != "bar" || r.Err != nil || !r.Shared {
+		t.Fatalf("DoChan = %+v; want bar, nil, shared", r)
+	}
+}
+
+func TestDoChan(t *testing.T) {
+	var g Group
+	ch := g.DoChan("key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	select {
+	case r := <-ch:
+		if r.Val != "bar" || r.Err != nil || r.Shared {
+			t.Fatalf("DoChan = %+v; want bar, nil, not shared", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DoChan timed out")
+	}
+}
+
+// Forget 之后的调用重新执行 fn，旧的调用结束时不会删除新的调用
+func TestForget(t *testing.T) {
+	var g Group
+	firstStarted := make(chan struct{})
+	unblockFirst := make(chan struct{})
+	first := g.DoChan("key", func() (interface{}, error) {
+		close(firstStarted)
+		<-unblockFirst
+		return 1, nil
+	})
+	<-firstStarted
+	g.Forget("key")
+
+	unblockSecond := make(chan struct{})
+	second := g.DoChan("key", func() (interface{}, error) {
+		<-unblockSecond
+		return 2, nil
+	})
+
+	close(unblockFirst)
+	if r := <-first; r.Val != 1 {
+		t.Fatalf("first = %v; want 1", r.Val)
+	}
+
+	third := g.DoChan("key", func() (interface{}, error) {
+		return 3, nil
+	})
+	close(unblockSecond)
+	if r := <-second; r.Val != 2 {
+		t.Fatalf("second = %v; want 2", r.Val)
+	}
+	if r := <-third; r.Val != 2 || !r.Shared {
+		t.Fatalf("third = %+v; want to share the second call", r)
+	}
+}
+
+// fn panic 时所有 Do 的调用者都 panic，不会一直等待
+func TestPanicDo(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		panic("invalid memory address or nil pointer dereference")
+	}
+
+	const n = 5
+	var wg sync.WaitGroup
+	var panicked int32
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					if _, ok := r.(*PanicError); ok {
+						atomic.AddInt32(&panicked, 1)
+					}
+				}
+			}()
+			g.Do(context.Background(), "key", fn)
+		}()
+	}
+	waitDups(t, &g, "key", n-1)
+	close(release)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Do hangs after fn panicked")
+	}
+	if panicked != n {
+		t.Fatalf("%d callers panicked with *PanicError, want %d", panicked, n)
+	}
+
+	// panic 之后 key 可以再次使用
+	if v, err, _ := g.Do(context.Background(), "key", func() (interface{}, error) { return "ok", nil }); v != "ok" || err != nil {
+		t.Fatalf("Do after panic = %v, %v", v, err)
+	}
+}
+
+func TestPanicDoChan(t *testing.T) {
+	var g Group
+	r := <-g.DoChan("key", func() (interface{}, error) {
+		panic(errors.New("boom"))
+	})
+	var pe *PanicError
+	if !errors.As(r.Err, &pe) || pe.Value.(error).Error() != "boom" || len(pe.Stack) == 0 {
+		t.Fatalf("DoChan error = %v; want *PanicError with stack", r.Err)
+	}
+}
+
+// fn 调用 runtime.Goexit 时 Do 的调用者也 Goexit，DoChan 返回 ErrGoexit
+func TestGoexitDo(t *testing.T) {
+	var g Group
+	fn := func() (interface{}, error) {
+		runtime.Goexit()
+		return nil, nil
+	}
+
+	done := make(chan bool)
+	go func() {
+		normal := false
+		defer func() { done <- normal }()
+		g.Do(context.Background(), "key", fn)
+		normal = true
+	}()
+	select {
+	case normal := <-done:
+		if normal {
+			t.Fatal("Do returned normally after fn called runtime.Goexit")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Do hangs after fn called runtime.Goexit")
+	}
+
+	if r := <-g.DoChan("key", fn); r.Err != ErrGoexit {
+		t.Fatalf("DoChan error = %v; want ErrGoexit", r.Err)
+	}
+}
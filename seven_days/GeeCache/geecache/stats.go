@@ -0,0 +1,179 @@
+package geecache
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+/*
+
+   1、AtomicInt 是可以并发累加的计数器，Group 和 cache 在各自的路径上累加，不需要加锁。
+   2、Group.Stats() 返回某一时刻的快照 Stats，MainCache、HotCache 和存放否定条目的 NegCache 分别统计。
+   3、HTTPPool 在 /<basepath>/_stats 返回所有 Group 的 JSON，在 /<basepath>/_metrics 返回 Prometheus 文本格式。
+
+*/
+
+// AtomicInt 原子计数器
+type AtomicInt int64
+
+// Add 原子地加上 n
+func (i *AtomicInt) Add(n int64) {
+	atomic.AddInt64((*int64)(i), n)
+}
+
+// Get 原子地读取
+func (i *AtomicInt) Get() int64 {
+	return atomic.LoadInt64((*int64)(i))
+}
+
+func (i *AtomicInt) String() string {
+	return strconv.FormatInt(i.Get(), 10)
+}
+
+// groupStats Group 的计数器
+type groupStats struct {
+	gets         AtomicInt // Get 的次数
+	hits         AtomicInt // 命中 mainCache 或 hotCache 的次数
+	negativeHits AtomicInt // 命中否定条目的次数
+	misses       AtomicInt // 未命中，需要 load 的次数
+	peerLoads    AtomicInt // 从 peer 成功取回的次数
+	peerErrors   AtomicInt // 从 peer 取值失败的次数
+	localLoads   AtomicInt // 调用 Getter 成功的次数，包括返回 ErrNotFound
+	localErrors  AtomicInt // 调用 Getter 失败的次数，不包括 ErrNotFound
+	loadsDeduped AtomicInt // 被 singleflight 合并、共用其他请求结果的次数
+}
+
+// cacheStats cache 的计数器，字节数和条目数由 cache 自己维护
+type cacheStats struct {
+	gets      AtomicInt
+	hits      AtomicInt
+	evictions AtomicInt // 容量淘汰和过期，不包括 Remove
+}
+
+// CacheStats 一个本地缓存的统计
+type CacheStats struct {
+	Bytes     int64 `json:"bytes"`
+	Items     int64 `json:"items"`
+	Gets      int64 `json:"gets"`
+	Hits      int64 `json:"hits"`
+	Evictions int64 `json:"evictions"`
+}
+
+// Stats Group 的统计快照
+type Stats struct {
+	Name         string     `json:"name"`
+	Gets         int64      `json:"gets"`
+	Hits         int64      `json:"hits"`
+	NegativeHits int64      `json:"negative_hits"`
+	Misses       int64      `json:"misses"`
+	PeerLoads    int64      `json:"peer_loads"`
+	PeerErrors   int64      `json:"peer_errors"`
+	LocalLoads   int64      `json:"local_loads"`
+	LocalErrors  int64      `json:"local_errors"`
+	LoadsDeduped int64      `json:"loads_deduped"`
+	MainCache    CacheStats `json:"main_cache"`
+	HotCache     CacheStats `json:"hot_cache"`
+	NegCache     CacheStats `json:"negative_cache"`
+}
+
+// Stats 返回 Group 当前的统计
+func (g *Group) Stats() Stats {
+	return Stats{
+		Name:         g.name,
+		Gets:         g.stats.gets.Get(),
+		Hits:         g.stats.hits.Get(),
+		NegativeHits: g.stats.negativeHits.Get(),
+		Misses:       g.stats.misses.Get(),
+		PeerLoads:    g.stats.peerLoads.Get(),
+		PeerErrors:   g.stats.peerErrors.Get(),
+		LocalLoads:   g.stats.localLoads.Get(),
+		LocalErrors:  g.stats.localErrors.Get(),
+		LoadsDeduped: g.stats.loadsDeduped.Get(),
+		MainCache:    g.mainCache.stats(),
+		HotCache:     g.hotCache.stats(),
+		NegCache:     g.negCache.stats(),
+	}
+}
+
+// AllStats 返回所有 Group 的统计，按名字排序
+func AllStats() []Stats {
+	mu.RLock()
+	all := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		all = append(all, g)
+	}
+	mu.RUnlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	stats := make([]Stats, len(all))
+	for i, g := range all {
+		stats[i] = g.Stats()
+	}
+	return stats
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出 stats，每个指标带有 group 标签，缓存相关的指标还带有 cache 标签
+func WritePrometheus(w io.Writer, stats []Stats) error {
+	counters := []struct {
+		name, help string
+		value      func(s *Stats) int64
+	}{
+		{"gets_total", "Number of Get calls.", func(s *Stats) int64 { return s.Gets }},
+		{"hits_total", "Number of Gets served from the local caches.", func(s *Stats) int64 { return s.Hits }},
+		{"negative_hits_total", "Number of Gets served from the negative cache.", func(s *Stats) int64 { return s.NegativeHits }},
+		{"misses_total", "Number of Gets that had to load the value.", func(s *Stats) int64 { return s.Misses }},
+		{"peer_loads_total", "Number of values loaded from peers.", func(s *Stats) int64 { return s.PeerLoads }},
+		{"peer_errors_total", "Number of failed peer loads.", func(s *Stats) int64 { return s.PeerErrors }},
+		{"local_loads_total", "Number of values loaded by the Getter.", func(s *Stats) int64 { return s.LocalLoads }},
+		{"local_errors_total", "Number of failed Getter calls.", func(s *Stats) int64 { return s.LocalErrors }},
+		{"loads_deduped_total", "Number of loads that shared another caller's result.", func(s *Stats) int64 { return s.LoadsDeduped }},
+	}
+	for _, c := range counters {
+		if _, err := fmt.Fprintf(w, "# HELP geecache_%s %s\n# TYPE geecache_%s counter\n", c.name, c.help, c.name); err != nil {
+			return err
+		}
+		for i := range stats {
+			if _, err := fmt.Fprintf(w, "geecache_%s{group=\"%s\"} %d\n", c.name, escapeLabel(stats[i].Name), c.value(&stats[i])); err != nil {
+				return err
+			}
+		}
+	}
+
+	caches := []struct {
+		name, help, typ string
+		value           func(c *CacheStats) int64
+	}{
+		{"cache_bytes", "Bytes used by the local cache.", "gauge", func(c *CacheStats) int64 { return c.Bytes }},
+		{"cache_items", "Number of items in the local cache.", "gauge", func(c *CacheStats) int64 { return c.Items }},
+		{"cache_gets_total", "Number of lookups in the local cache.", "counter", func(c *CacheStats) int64 { return c.Gets }},
+		{"cache_hits_total", "Number of hits in the local cache.", "counter", func(c *CacheStats) int64 { return c.Hits }},
+		{"cache_evictions_total", "Number of entries evicted or expired.", "counter", func(c *CacheStats) int64 { return c.Evictions }},
+	}
+	for _, c := range caches {
+		if _, err := fmt.Fprintf(w, "# HELP geecache_%s %s\n# TYPE geecache_%s %s\n", c.name, c.help, c.name, c.typ); err != nil {
+			return err
+		}
+		for i := range stats {
+			s := &stats[i]
+			for _, cs := range []struct {
+				label string
+				stats *CacheStats
+			}{{"main", &s.MainCache}, {"hot", &s.HotCache}, {"negative", &s.NegCache}} {
+				if _, err := fmt.Fprintf(w, "geecache_%s{group=\"%s\",cache=\"%s\"} %d\n", c.name, escapeLabel(s.Name), cs.label, c.value(cs.stats)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Prometheus 标签值只需要转义反斜杠、双引号和换行，%q 还会转义非 ASCII 字符，与格式不符
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,118 @@
+package geecache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	release := make(chan struct{})
+	gee := NewGroup("stats", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if key == "slow" {
+			<-release
+			return []byte("slow"), nil
+		}
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("%s not exist", key)
+	}))
+
+	gee.Get("Tom")
+	gee.Get("Tom")
+	gee.Get("unknown")
+
+	// 5 个并发的 slow 只加载一次，其余 4 个被合并
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gee.Get("slow")
+		}()
+	}
+	// 等待其余 4 个调用者加入 slow 的请求之后再放行
+	for deadline := time.Now().Add(time.Second); gee.loader.Dups("slow") != 4; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers joined the slow load, want 4", gee.loader.Dups("slow"))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	s := gee.Stats()
+	if s.Gets != 8 || s.Hits != 1 || s.Misses != 7 {
+		t.Fatalf("gets/hits/misses = %d/%d/%d, want 8/1/7", s.Gets, s.Hits, s.Misses)
+	}
+	if s.LocalLoads != 2 || s.LocalErrors != 1 || s.LoadsDeduped != 4 {
+		t.Fatalf("local loads/errors/deduped = %d/%d/%d, want 2/1/4", s.LocalLoads, s.LocalErrors, s.LoadsDeduped)
+	}
+	if s.MainCache.Items != 2 || s.MainCache.Bytes != int64(len("Tom")+len(db["Tom"])+8) || s.MainCache.Hits != 1 {
+		t.Fatalf("unexpected main cache stats: %+v", s.MainCache)
+	}
+}
+
+func TestStatsEndpoints(t *testing.T) {
+	gee := NewGroup("stats-http", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	gee.Get("Tom")
+	srv := httptest.NewServer(NewHTTPPool("test"))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + defaultBasePath + "/" + statsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []Stats
+	err = json.NewDecoder(res.Body).Decode(&all)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range all {
+		if s.Name == "stats-http" {
+			found = s.Gets == 1 && s.LocalLoads == 1
+		}
+	}
+	if !found {
+		t.Fatalf("stats-http missing or wrong in %+v", all)
+	}
+
+	res, err = http.Get(srv.URL + defaultBasePath + "/" + metricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	for _, line := range []string{
+		"# TYPE geecache_gets_total counter",
+		`geecache_gets_total{group="stats-http"} 1`,
+		`geecache_cache_items{group="stats-http",cache="main"} 1`,
+	} {
+		if !strings.Contains(string(body), line) {
+			t.Fatalf("metrics missing %q:\n%s", line, body)
+		}
+	}
+}
+
+// 标签值只转义反斜杠、双引号和换行，非 ASCII 字符原样输出
+func TestWritePrometheusEscape(t *testing.T) {
+	var buf strings.Builder
+	if err := WritePrometheus(&buf, []Stats{{Name: "a\"b\\c\nd-缓存"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := `geecache_gets_total{group="a\"b\\c\nd-缓存"} 0`
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("metrics missing %s:\n%s", want, buf.String())
+	}
+}
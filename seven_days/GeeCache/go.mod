@@ -2,4 +2,9 @@ module GeeCache
 
 go 1.18
 
-require google.golang.org/protobuf v1.28.1
+require (
+	GeeRPC v0.0.0-00010101000000-000000000000
+	google.golang.org/protobuf v1.28.1
+)
+
+replace GeeRPC => ../GeeRPC
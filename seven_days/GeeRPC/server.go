@@ -2,6 +2,7 @@ package geerpc
 
 import (
 	"GeeRPC/codec"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -102,7 +103,8 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 	}()
 	var opt Option
 	// 1、反序列化得到 option 实例
-	if err := json.NewDecoder(conn).Decode(&opt); err != nil {
+	dec := json.NewDecoder(conn)
+	if err := dec.Decode(&opt); err != nil {
 		log.Println("rpc server: options error: ", err)
 		return
 	}
@@ -117,8 +119,27 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 		log.Printf("rpc server: invalid codec type %s", opt.CodecType)
 		return
 	}
-	// 4、解码
-	server.serveCodec(f(conn), &opt)
+	// 4、解码，json.Decoder 可能已经读入了 Option 之后的请求，需要交给 codec 继续读取，
+	// json.Encoder 在 Option 之后写入的换行符可能还没有到达，从合并后的 reader 中读出并丢弃
+	r := io.MultiReader(dec.Buffered(), conn)
+	var first [1]byte
+	if _, err := io.ReadFull(r, first[:]); err != nil {
+		return
+	}
+	if first[0] != '\n' {
+		r = io.MultiReader(bytes.NewReader(first[:]), r)
+	}
+	server.serveCodec(f(&optionConn{r: r, ReadWriteCloser: conn}), &opt)
+}
+
+// optionConn 先读取 json.Decoder 中剩余的数据，再从连接中读取
+type optionConn struct {
+	r io.Reader
+	io.ReadWriteCloser
+}
+
+func (c *optionConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
 }
 
 // invalidRequest 是发生错误时响应argv的占位符
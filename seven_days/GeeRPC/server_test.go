@@ -0,0 +1,36 @@
+package geerpc
+
+import (
+	"GeeRPC/codec"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+// Option 和之后的换行符分两次到达时，换行符不能被当作请求的一部分
+func TestServeConn_splitOption(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	_assert(server.Register(&foo) == nil, "failed to register Foo")
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	opt := *DefaultOption
+	data, err := json.Marshal(&opt)
+	_assert(err == nil, "failed to marshal option: %v", err)
+	_, err = clientConn.Write(data)
+	_assert(err == nil, "failed to write option: %v", err)
+	time.Sleep(10 * time.Millisecond) // 等待服务端解码 Option
+	_, err = clientConn.Write([]byte("\n"))
+	_assert(err == nil, "failed to write newline: %v", err)
+
+	client := newClientCodec(codec.NewGobCodec(clientConn), &opt)
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply int
+	err = client.Call(ctx, "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	_assert(err == nil && reply == 3, "Foo.Sum = %d, %v; want 3", reply, err)
+}